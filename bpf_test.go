@@ -3,88 +3,122 @@ package lcm
 import (
 	"testing"
 
+	"go.einride.tech/lcm/lcmbpf"
 	"golang.org/x/net/bpf"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	"gotest.tools/v3/assert"
 )
 
-func TestShortMessageChannelFilter(t *testing.T) {
+func TestReceiverOptions_ReceiverBPF(t *testing.T) {
 	for _, tt := range []struct {
 		name     string
-		program  []bpf.Instruction
+		opts     []ReceiverOption
 		packet   []byte
 		expected int
 	}{
 		{
-			name:    "accepted 1",
-			program: shortMessageChannelFilter("foo", "barbaz"),
+			name:     "default accepted",
+			packet:   shortMessagePacket(1, "foo"),
+			expected: 0xffff,
+		},
+		{
+			name: "default rejected",
 			packet: []byte{
 				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
-				0x4c, 0x43, 0x30, 0x32, // magic
+				0x4c, 0x43, 0x30, 0x00, // wrong magic
 				0x00, 0x00, 0x00, 0x01, // sequence number
 				'f', 'o', 'o', 0, // channel
 			},
+			expected: 0,
+		},
+		{
+			name:     "protos accepted",
+			opts:     []ReceiverOption{WithReceiveProtos(&timestamppb.Timestamp{}, &durationpb.Duration{})},
+			packet:   shortMessagePacket(1, "google.protobuf.Duration"),
 			expected: 0xffff,
 		},
 		{
-			name:    "accepted query parameters",
-			program: shortMessageChannelFilter("foo", "barbaz"),
-			packet: []byte{
-				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
-				0x4c, 0x43, 0x30, 0x32, // magic
-				0x00, 0x00, 0x00, 0x01, // sequence number
-				'b', 'a', 'r', 'b', 'a', 'z', '?', 'm', '=', '1', 0, // channel
-			},
+			name:     "protos rejected",
+			opts:     []ReceiverOption{WithReceiveProtos(&timestamppb.Timestamp{}, &durationpb.Duration{})},
+			packet:   shortMessagePacket(1, "foo"),
+			expected: 0,
+		},
+		{
+			name:     "channels accepted",
+			opts:     []ReceiverOption{WithReceiveChannels("foo", "vehicle.*")},
+			packet:   shortMessagePacket(1, "vehicle.state"),
 			expected: 0xffff,
 		},
 		{
-			name:    "accepted 2",
-			program: shortMessageChannelFilter("foo", "barbaz"),
-			packet: []byte{
-				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
-				0x4c, 0x43, 0x30, 0x32, // magic
-				0x00, 0x00, 0x00, 0x01, // sequence number
-				'b', 'a', 'r', 'b', 'a', 'z', 0, 0, // channel
-			},
+			name:     "channels and protos accepted",
+			opts:     []ReceiverOption{WithReceiveChannels("foo"), WithReceiveProtos(&timestamppb.Timestamp{})},
+			packet:   shortMessagePacket(1, "google.protobuf.Timestamp"),
 			expected: 0xffff,
 		},
 		{
-			name:    "accepted 3",
-			program: shortMessageChannelFilter("foo", "tutan"),
-			packet: append(
-				[]byte{
-					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
-					0x4c, 0x43, 0x30, 0x32, // magic
-					0x00, 0x00, 0x00, 0x01, // sequence number
-				},
-				[]byte("tutan\x00")..., // channel
-			),
+			name:     "shard accepted",
+			opts:     []ReceiverOption{withReceiveShard(2, int(lcmbpf.ChannelHash("foo")%2))},
+			packet:   shortMessagePacket(1, "foo"),
 			expected: 0xffff,
 		},
 		{
-			name:    "rejected due to wrong c1hannel",
-			program: shortMessageChannelFilter("foo", "barbaz"),
-			packet: []byte{
-				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
-				0x4c, 0x43, 0x30, 0x32, // magic
-				0x00, 0x00, 0x00, 0x01, // sequence number
-				'b', 'a', 'r', 0, // channel
+			name:     "shard rejected",
+			opts:     []ReceiverOption{withReceiveShard(2, 1-int(lcmbpf.ChannelHash("foo")%2))},
+			packet:   shortMessagePacket(1, "foo"),
+			expected: 0,
+		},
+		{
+			name:     "channels rejected",
+			opts:     []ReceiverOption{WithReceiveChannels("foo", "vehicle.*")},
+			packet:   shortMessagePacket(1, "bar"),
+			expected: 0,
+		},
+		{
+			name: "protos and filter accepted",
+			opts: []ReceiverOption{
+				WithReceiveProtos(&timestamppb.Timestamp{}),
+				WithReceiveFilter(lcmbpf.SourcePort(0)),
 			},
+			packet:   shortMessagePacket(1, "google.protobuf.Timestamp"),
+			expected: 0xffff,
+		},
+		{
+			name: "protos and filter rejected",
+			opts: []ReceiverOption{
+				WithReceiveProtos(&timestamppb.Timestamp{}),
+				WithReceiveFilter(lcmbpf.SourcePort(1234)),
+			},
+			packet:   shortMessagePacket(1, "google.protobuf.Timestamp"),
 			expected: 0,
 		},
 		{
-			name:    "rejected due to wrong header magic",
-			program: shortMessageChannelFilter("foo", "barbaz"),
-			packet: []byte{
-				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
-				0x4c, 0x43, 0x30, 0x00, // wrong magic
-				0x00, 0x00, 0x00, 0x01, // sequence number
-				'f', 'o', 'o', 0, // channel
+			name: "protos and BPF rejected",
+			opts: []ReceiverOption{
+				WithReceiveProtos(&timestamppb.Timestamp{}),
+				WithReceiveBPF([]bpf.Instruction{bpf.RetConstant{Val: 0}}),
 			},
+			packet:   shortMessagePacket(1, "google.protobuf.Timestamp"),
+			expected: 0,
+		},
+		{
+			name: "BPF and protos rejected",
+			opts: []ReceiverOption{
+				WithReceiveBPF([]bpf.Instruction{bpf.RetConstant{Val: 0xffff}}),
+				WithReceiveProtos(&timestamppb.Timestamp{}),
+			},
+			packet:   shortMessagePacket(1, "foo"),
 			expected: 0,
 		},
 	} {
 		t.Run(tt.name, func(t *testing.T) {
-			vm, err := bpf.NewVM(tt.program)
+			opts := defaultReceiverOptions()
+			for _, opt := range tt.opts {
+				opt(opts)
+			}
+			program, err := opts.receiverBPF(newChannelSubscription(opts.channels, opts.protos))
+			assert.NilError(t, err)
+			vm, err := bpf.NewVM(program)
 			assert.NilError(t, err)
 			n, err := vm.Run(tt.packet)
 			assert.NilError(t, err)
@@ -92,3 +126,15 @@ func TestShortMessageChannelFilter(t *testing.T) {
 		})
 	}
 }
+
+// shortMessagePacket returns a UDP packet with an LCM short message.
+func shortMessagePacket(sequenceNumber byte, channel string, payload ...byte) []byte {
+	packet := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
+		0x4c, 0x43, 0x30, 0x32, // magic
+		0x00, 0x00, 0x00, sequenceNumber, // sequence number
+	}
+	packet = append(packet, channel...)
+	packet = append(packet, 0)
+	return append(packet, payload...)
+}
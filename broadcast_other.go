@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package lcm
+
+import (
+	"errors"
+	"net"
+)
+
+// enableBroadcast is not supported on platforms without SO_BROADCAST.
+func enableBroadcast(*net.UDPConn) error {
+	return errors.New("enable broadcast: not supported on this platform")
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package lcm
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableBroadcast enables transmitting to broadcast addresses on the connection.
+func enableBroadcast(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("enable broadcast: %w", err)
+	}
+	var sockoptErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockoptErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+	}); err != nil {
+		return fmt.Errorf("enable broadcast: %w", err)
+	}
+	if sockoptErr != nil {
+		return fmt.Errorf("enable broadcast: %w", sockoptErr)
+	}
+	return nil
+}
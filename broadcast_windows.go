@@ -0,0 +1,25 @@
+package lcm
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// enableBroadcast enables transmitting to broadcast addresses on the connection.
+func enableBroadcast(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("enable broadcast: %w", err)
+	}
+	var sockoptErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockoptErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	}); err != nil {
+		return fmt.Errorf("enable broadcast: %w", err)
+	}
+	if sockoptErr != nil {
+		return fmt.Errorf("enable broadcast: %w", sockoptErr)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package lcm
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DiscoveryChannel is the channel of announcements, published by transmitters configured with
+// WithTransmitAnnouncements.
+//
+// Each message is a JSON-encoded Announcement.
+const DiscoveryChannel = "lcm.discovery"
+
+// lengthOfLargestAnnouncement is the length in bytes of the largest announcement that fits in a datagram on
+// DiscoveryChannel.
+const lengthOfLargestAnnouncement = lengthOfLargestPayload - len(DiscoveryChannel) - 1
+
+// Announcement is a heartbeat announcing an LCM node and the channels it publishes.
+type Announcement struct {
+	// Node is the name of the node.
+	Node string `json:"node"`
+	// Host is the host name of the node.
+	Host string `json:"host"`
+	// PID is the process ID of the node.
+	PID int `json:"pid"`
+	// Channels are the channels published by the node.
+	Channels []AnnouncedChannel `json:"channels"`
+}
+
+// AnnouncedChannel is a channel published by an LCM node.
+type AnnouncedChannel struct {
+	// Name is the name of the channel.
+	Name string `json:"name"`
+	// Type is the full name of the proto message type of the channel, if known.
+	Type string `json:"type,omitempty"`
+}
+
+// observeChannel records a channel published by the transmitter, with the proto message type if known.
+//
+// Must be called with the transmitter lock held.
+func (t *Transmitter) observeChannel(channel, typeName string) {
+	if t.opts.announceInterval <= 0 || channel == DiscoveryChannel {
+		return
+	}
+	if t.channels == nil {
+		t.channels = make(map[string]string)
+	}
+	if _, ok := t.channels[channel]; !ok || typeName != "" {
+		t.channels[channel] = typeName
+	}
+}
+
+// announce transmits an announcement of the transmitter and the channels it has published.
+//
+// The channels are split across multiple announcements when they don't fit in a single datagram.
+func (t *Transmitter) announce(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	host, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("announce: %w", err)
+	}
+	announcement := Announcement{
+		Node:     t.opts.nodeName,
+		Host:     host,
+		PID:      os.Getpid(),
+		Channels: make([]AnnouncedChannel, 0, len(t.channels)),
+	}
+	for channel, typeName := range t.channels {
+		announcement.Channels = append(announcement.Channels, AnnouncedChannel{Name: channel, Type: typeName})
+	}
+	slices.SortFunc(announcement.Channels, func(a, b AnnouncedChannel) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	announcements, err := marshalAnnouncements(announcement, lengthOfLargestAnnouncement)
+	if err != nil {
+		return fmt.Errorf("announce: %w", err)
+	}
+	for _, data := range announcements {
+		if err := t.transmit(ctx, DiscoveryChannel, "", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalAnnouncements marshals the announcement, split into announcements of at most maxLength bytes with a part of
+// the channels each.
+func marshalAnnouncements(announcement Announcement, maxLength int) ([][]byte, error) {
+	channels := announcement.Channels
+	announcement.Channels = []AnnouncedChannel{}
+	header, err := json.Marshal(&announcement)
+	if err != nil {
+		return nil, err
+	}
+	var result [][]byte
+	for first := true; first || len(channels) > 0; first = false {
+		// each channel adds its encoding and a separating comma
+		n, length := 0, len(header)
+		for ; n < len(channels); n++ {
+			channel, err := json.Marshal(&channels[n])
+			if err != nil {
+				return nil, err
+			}
+			if length+len(channel)+1 > maxLength {
+				break
+			}
+			length += len(channel) + 1
+		}
+		if n == 0 && len(channels) > 0 {
+			return nil, fmt.Errorf("channel %s too long to announce", channels[0].Name)
+		}
+		announcement.Channels = append([]AnnouncedChannel{}, channels[:n]...)
+		data, err := json.Marshal(&announcement)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+		channels = channels[n:]
+	}
+	return result, nil
+}
+
+// DiscoveredNode is an LCM node discovered from its announcements, or a sender discovered from its traffic.
+type DiscoveredNode struct {
+	// Node is the announced name of the node, or empty if the node has not been announced.
+	Node string
+	// Host is the announced host name of the node.
+	Host string
+	// PID is the announced process ID of the node.
+	PID int
+	// Address is the source address of the node.
+	Address net.IP
+	// Port is the source port of the node.
+	Port int
+	// Path is the socket path of the node, when discovered by a Unix datagram receiver.
+	Path string
+	// Channels are the channels published by the node, announced or received, sorted by name.
+	Channels []AnnouncedChannel
+	// LastSeen is the time the node was last announced or received from.
+	LastSeen time.Time
+}
+
+// Discovery builds a live graph of the LCM nodes on a network, from their announcements and received traffic.
+//
+// Nodes are identified by their source address and port, or by their socket path for Unix datagram receivers, so the
+// announcements and the traffic of a transmitter are attributed to the same node. Transmitters that can not be told
+// apart, such as the single transmitter of a shared-memory receiver, or Unix datagram transmitters outside Linux where
+// their sockets are unnamed, are discovered as a single node.
+//
+// Safe for concurrent use.
+type Discovery struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	nodes   map[senderSource]*discoveredNode
+	now     func() time.Time
+}
+
+// discoveredNode is the discovery state of a node.
+type discoveredNode struct {
+	announcement Announcement
+	address      net.IP
+	channels     map[string]string
+	lastSeen     time.Time
+}
+
+// NewDiscovery returns a Discovery forgetting nodes that have not been seen within the provided timeout.
+//
+// The timeout should be a few times the announcement interval of the nodes.
+func NewDiscovery(timeout time.Duration) *Discovery {
+	return &Discovery{
+		timeout: timeout,
+		nodes:   make(map[senderSource]*discoveredNode),
+		now:     time.Now,
+	}
+}
+
+// Observe the last message received by the receiver.
+//
+// The receiver should be subscribed to DiscoveryChannel, and to the other channels to observe traffic on.
+// Announcements that can not be decoded result in an error.
+func (d *Discovery) Observe(rx *Receiver) error {
+	m := rx.Message()
+	var announcement *Announcement
+	if m.Channel == DiscoveryChannel {
+		announcement = new(Announcement)
+		if err := json.Unmarshal(m.Data, announcement); err != nil {
+			return fmt.Errorf("observe announcement: %w", err)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node, ok := d.nodes[rx.source]
+	if !ok {
+		node = &discoveredNode{channels: make(map[string]string)}
+		d.nodes[rx.source] = node
+	}
+	node.address = rx.SourceAddress()
+	node.lastSeen = d.now()
+	if announcement != nil {
+		node.announcement = *announcement
+		for _, channel := range announcement.Channels {
+			node.addChannel(channel.Name, channel.Type)
+		}
+		return nil
+	}
+	node.addChannel(m.Channel, m.Param(paramType))
+	return nil
+}
+
+// addChannel records a channel published by the node, keeping the proto message type if already known.
+func (n *discoveredNode) addChannel(channel, typeName string) {
+	if existing, ok := n.channels[channel]; !ok || existing == "" {
+		n.channels[channel] = typeName
+	}
+}
+
+// Nodes returns the nodes seen within the timeout, sorted by name, source address and port, and socket path.
+func (d *Discovery) Nodes() []DiscoveredNode {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	nodes := make([]DiscoveredNode, 0, len(d.nodes))
+	for source, node := range d.nodes {
+		if now.Sub(node.lastSeen) > d.timeout {
+			delete(d.nodes, source)
+			continue
+		}
+		discovered := DiscoveredNode{
+			Node:     node.announcement.Node,
+			Host:     node.announcement.Host,
+			PID:      node.announcement.PID,
+			Address:  node.address,
+			Port:     int(source.addrPort.Port()),
+			Path:     source.path,
+			Channels: make([]AnnouncedChannel, 0, len(node.channels)),
+			LastSeen: node.lastSeen,
+		}
+		for channel, typeName := range node.channels {
+			discovered.Channels = append(discovered.Channels, AnnouncedChannel{Name: channel, Type: typeName})
+		}
+		slices.SortFunc(discovered.Channels, func(a, b AnnouncedChannel) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		nodes = append(nodes, discovered)
+	}
+	slices.SortFunc(nodes, func(a, b DiscoveredNode) int {
+		return cmp.Or(
+			cmp.Compare(a.Node, b.Node),
+			slices.Compare(a.Address, b.Address),
+			cmp.Compare(a.Port, b.Port),
+			cmp.Compare(a.Path, b.Path),
+		)
+	})
+	return nodes
+}
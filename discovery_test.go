@@ -0,0 +1,128 @@
+package lcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDiscovery(t *testing.T) {
+	now := time.Unix(1, 0)
+	d := NewDiscovery(3 * time.Second)
+	d.now = func() time.Time {
+		return now
+	}
+	observe := func(t *testing.T, source string, m Message) {
+		t.Helper()
+		addrPort := netip.MustParseAddrPort(source)
+		rx := &Receiver{currMessage: m, source: senderSource{addrPort: addrPort}, srcAddr: net.IP(addrPort.Addr().AsSlice())}
+		assert.NilError(t, d.Observe(rx))
+	}
+	t.Run("traffic", func(t *testing.T) {
+		observe(t, "10.0.0.1:1000", Message{Channel: "camera.front", Params: "t=example.Image"})
+		observe(t, "10.0.0.2:2000", Message{Channel: "lidar"})
+		assert.DeepEqual(t, []DiscoveredNode{
+			{
+				Address:  net.IPv4(10, 0, 0, 1).To4(),
+				Port:     1000,
+				Channels: []AnnouncedChannel{{Name: "camera.front", Type: "example.Image"}},
+				LastSeen: now,
+			},
+			{
+				Address:  net.IPv4(10, 0, 0, 2).To4(),
+				Port:     2000,
+				Channels: []AnnouncedChannel{{Name: "lidar"}},
+				LastSeen: now,
+			},
+		}, d.Nodes())
+	})
+	t.Run("announcement", func(t *testing.T) {
+		now = now.Add(2 * time.Second)
+		observe(t, "10.0.0.1:1000", Message{
+			Channel: DiscoveryChannel,
+			Data: []byte(`{"node":"camera","host":"vehicle","pid":42,"channels":[` +
+				`{"name":"camera.front"},{"name":"camera.rear","type":"example.Image"}]}`),
+		})
+		assert.DeepEqual(t, []DiscoveredNode{
+			{
+				Address:  net.IPv4(10, 0, 0, 2).To4(),
+				Port:     2000,
+				Channels: []AnnouncedChannel{{Name: "lidar"}},
+				LastSeen: now.Add(-2 * time.Second),
+			},
+			{
+				Node:    "camera",
+				Host:    "vehicle",
+				PID:     42,
+				Address: net.IPv4(10, 0, 0, 1).To4(),
+				Port:    1000,
+				Channels: []AnnouncedChannel{
+					{Name: "camera.front", Type: "example.Image"},
+					{Name: "camera.rear", Type: "example.Image"},
+				},
+				LastSeen: now,
+			},
+		}, d.Nodes())
+	})
+	t.Run("timeout", func(t *testing.T) {
+		now = now.Add(2 * time.Second)
+		nodes := d.Nodes()
+		assert.Equal(t, 1, len(nodes))
+		assert.Equal(t, "camera", nodes[0].Node)
+	})
+	t.Run("unix socket", func(t *testing.T) {
+		rx := &Receiver{currMessage: Message{Channel: "radar"}, source: senderSource{path: "@lcm.1.1"}}
+		assert.NilError(t, d.Observe(rx))
+		nodes := d.Nodes()
+		assert.Equal(t, 2, len(nodes))
+		assert.Equal(t, "@lcm.1.1", nodes[0].Path)
+		assert.DeepEqual(t, []AnnouncedChannel{{Name: "radar"}}, nodes[0].Channels)
+	})
+	t.Run("malformed announcement", func(t *testing.T) {
+		rx := &Receiver{currMessage: Message{Channel: DiscoveryChannel, Data: []byte("{")}}
+		assert.ErrorContains(t, d.Observe(rx), "observe announcement")
+	})
+}
+
+func TestMarshalAnnouncements(t *testing.T) {
+	announcement := Announcement{Node: "camera", Host: "vehicle", PID: 42}
+	for i := range 20 {
+		announcement.Channels = append(announcement.Channels, AnnouncedChannel{
+			Name: fmt.Sprintf("camera.%d", i),
+			Type: "example.Image",
+		})
+	}
+	t.Run("single", func(t *testing.T) {
+		announcements, err := marshalAnnouncements(announcement, lengthOfLargestAnnouncement)
+		assert.NilError(t, err)
+		assert.Equal(t, 1, len(announcements))
+	})
+	t.Run("split", func(t *testing.T) {
+		announcements, err := marshalAnnouncements(announcement, 300)
+		assert.NilError(t, err)
+		assert.Assert(t, len(announcements) > 1)
+		var channels []AnnouncedChannel
+		for _, data := range announcements {
+			assert.Assert(t, len(data) <= 300)
+			var split Announcement
+			assert.NilError(t, json.Unmarshal(data, &split))
+			assert.Equal(t, "camera", split.Node)
+			channels = append(channels, split.Channels...)
+		}
+		assert.DeepEqual(t, announcement.Channels, channels)
+	})
+	t.Run("no channels", func(t *testing.T) {
+		announcements, err := marshalAnnouncements(Announcement{Node: "camera"}, 300)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, [][]byte{[]byte(`{"node":"camera","host":"","pid":0,"channels":[]}`)}, announcements)
+	})
+	t.Run("channel too long", func(t *testing.T) {
+		_, err := marshalAnnouncements(announcement, 100)
+		assert.ErrorContains(t, err, "too long to announce")
+	})
+}
@@ -0,0 +1,52 @@
+package lcm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Errors for malformed LCM messages.
+var (
+	// ErrInsufficientData is returned when a datagram is too short to be an LCM message.
+	ErrInsufficientData = errors.New("insufficient data")
+	// ErrWrongHeaderMagic is returned when a datagram does not start with the LCM short message header magic.
+	ErrWrongHeaderMagic = errors.New("wrong header magic")
+	// ErrInvalidChannel is returned when a datagram does not contain a valid channel.
+	ErrInvalidChannel = errors.New("invalid channel")
+	// ErrUnsupportedParams is returned when a message has channel params that are not supported.
+	ErrUnsupportedParams = errors.New("unsupported params")
+)
+
+// ErrUnregisteredProto is returned by Receiver.NewProto when no proto message is registered for the type of the
+// received message. It wraps protoregistry.NotFound.
+var ErrUnregisteredProto = fmt.Errorf("unregistered proto message: %w", protoregistry.NotFound)
+
+// MalformedMessageError is returned by a Receiver when a received datagram is not a valid LCM message, or when its
+// payload can not be decompressed or decoded.
+type MalformedMessageError struct {
+	// Source is the source address of the datagram, if known.
+	Source net.Addr
+	// Channel is the channel of the message, if it could be decoded.
+	Channel string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *MalformedMessageError) Error() string {
+	if e.Channel != "" {
+		return fmt.Sprintf("malformed message on channel %s: %v", e.Channel, e.Err)
+	}
+	if e.Source != nil {
+		return fmt.Sprintf("malformed message from %v: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("malformed message: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *MalformedMessageError) Unwrap() error {
+	return e.Err
+}
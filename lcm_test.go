@@ -1,15 +1,30 @@
 package lcm
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"go.einride.tech/lcm/compression/lcmlz4"
+	"go.einride.tech/lcm/lcmbpf"
+	"golang.org/x/net/bpf"
 	"golang.org/x/net/nettest"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/testing/protocmp"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -72,6 +87,12 @@ func TestLCM_OneTransmitter_OneReceiver(t *testing.T) {
 		assert.DeepEqual(t, []byte("bar"), rx.Message().Data)
 		assert.Equal(t, uint32(1), rx.Message().SequenceNumber)
 	})
+	t.Run("stats", func(t *testing.T) {
+		stats := rx.Stats()
+		assert.Equal(t, SequenceStats{Received: 2}, stats.SequenceStats)
+		assert.Equal(t, 1, len(stats.Senders))
+		assert.DeepEqual(t, map[string]uint64{"first": 1, "second": 1}, stats.Senders[0].Channels)
+	})
 }
 
 func TestLCM_OneTransmitter_MultipleReceivers(t *testing.T) {
@@ -308,23 +329,1869 @@ func TestLCM_ProtoTransmitter_ProtoReceiver(t *testing.T) {
 	})
 }
 
-func getInterface(t *testing.T) *net.Interface {
-	t.Helper()
-	ifi, err := nettest.RoutedInterface("ip4", net.FlagUp|net.FlagMulticast|net.FlagLoopback)
-	if err == nil {
-		return ifi
+func TestLCM_Receiver_ListenError(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("open file descriptors are only listed on Linux")
 	}
-	ifi, err = nettest.RoutedInterface("ip4", net.FlagUp|net.FlagMulticast)
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	before := countOpenFiles(t)
+	// when the receiver is configured with an interface that doesn't exist
+	_, err := ListenMulticastUDP(ctx, WithReceivePort(getFreePort(t)), WithReceiveInterface("nonexistent0"))
+	// then listening should fail without leaking the socket
+	assert.Assert(t, err != nil)
+	assert.Equal(t, before, countOpenFiles(t))
+}
+
+func TestLCM_Transmitter_DialError(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	t.Run("nonexistent interface", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("open file descriptors are only listed on Linux")
+		}
+		before := countOpenFiles(t)
+		// when the transmitter is configured with an interface that doesn't exist
+		_, err := DialMulticastUDP(ctx, WithTransmitInterface("nonexistent0"))
+		// then dialing should fail without leaking the socket
+		assert.Assert(t, err != nil)
+		assert.Equal(t, before, countOpenFiles(t))
+	})
+	t.Run("non-multicast interface", func(t *testing.T) {
+		ifis, err := net.Interfaces()
+		assert.NilError(t, err)
+		index := slices.IndexFunc(ifis, func(ifi net.Interface) bool {
+			return ifi.Flags&net.FlagMulticast == 0 || ifi.Flags&net.FlagUp == 0
+		})
+		if index == -1 {
+			t.Skip("no interface that is down or doesn't support multicast")
+		}
+		// when the transmitter is configured with an interface that is down or doesn't support multicast
+		_, err = DialMulticastUDP(ctx, WithTransmitInterface(ifis[index].Name))
+		// then dialing should fail
+		assert.ErrorContains(t, err, ifis[index].Name)
+	})
+}
+
+func TestLCM_Receiver_Cancel(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
 	assert.NilError(t, err)
-	return ifi
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the context of a blocked receive is canceled
+	cancelCtx, cancelReceive := context.WithCancel(ctx)
+	time.AfterFunc(10*time.Millisecond, cancelReceive)
+	start := time.Now()
+	err = rx.Receive(cancelCtx)
+	// then the receive should be interrupted with the context error
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Assert(t, time.Since(start) < testTimeout/2)
+	// and the interrupt should not affect the next receive
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "foo", rx.Message().Channel)
 }
 
-func getFreePort(t *testing.T) int {
-	t.Helper()
-	l, err := nettest.NewLocalPacketListener("udp4")
+func TestLCM_OneTransmitter_OneReceiver_KernelDrops(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("kernel drop counters are only supported on Linux")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	rx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveAddress(ip),
+		WithReceiveBufferSize(1),
+	)
 	assert.NilError(t, err)
 	defer func() {
-		assert.NilError(t, l.Close())
+		assert.NilError(t, rx.Close())
 	}()
-	return l.LocalAddr().(*net.UDPAddr).Port
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits more than fits in the receive buffer
+	for i := 0; i < 100; i++ {
+		assert.NilError(t, tx.Transmit(ctx, "foo", make([]byte, 1000)))
+	}
+	// and the receiver drains the receive buffer
+	for {
+		drainCtx, drainCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		err := rx.Receive(drainCtx)
+		drainCancel()
+		if err != nil {
+			break
+		}
+	}
+	assert.Equal(t, uint64(0), rx.Stats().Discarded)
+	// and the transmitter transmits once more
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	assert.NilError(t, rx.Receive(ctx))
+	// then the receiver should report the kernel drops
+	stats := rx.Stats()
+	assert.Assert(t, stats.Discarded > 0)
+	assert.Equal(t, stats.Discarded, stats.BatchDiscarded)
+	assert.Equal(t, stats.Discarded+stats.Received, uint64(101))
+}
+
+func TestLCM_OneTransmitter_OneReceiver_KernelDiscardsFiltered(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("kernel drop counters are only supported on Linux")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveChannels("foo"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits on a channel rejected by the filter, and then on the subscribed channel
+	for i := 0; i < 10; i++ {
+		assert.NilError(t, tx.Transmit(ctx, "bar", []byte("baz")))
+	}
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	assert.NilError(t, rx.Receive(ctx))
+	// then the filtered datagrams should be counted as discarded by the kernel
+	stats := rx.Stats()
+	assert.Equal(t, "foo", rx.Message().Channel)
+	assert.Equal(t, uint64(10), stats.Discarded)
+	assert.Equal(t, uint64(10), stats.BatchDiscarded)
+	assert.Equal(t, uint64(1), stats.Received)
+}
+
+func TestLCM_Receiver_BPFFallbackLogged(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	var logs bytes.Buffer
+	var metrics recordingMetrics
+	program := make([]bpf.Instruction, 0, lcmbpf.MaxInstructions+1)
+	for len(program) < cap(program)-1 {
+		program = append(program, bpf.LoadConstant{Val: 0})
+	}
+	program = append(program, bpf.RetConstant{Val: lengthOfLargestUDPMessage})
+	// when the receiver is configured with a too large BPF program
+	rx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveBPF(program),
+		WithReceiveLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithReceiveMetrics(&metrics),
+	)
+	assert.NilError(t, err)
+	assert.NilError(t, rx.Close())
+	// then the fallback should be logged and reported
+	assert.Assert(t, strings.Contains(logs.String(), "level=WARN msg=\"skipped BPF program"), logs.String())
+	assert.DeepEqual(t, []string{fmt.Sprintf("BPFFallback %d", len(program))}, metrics.events())
+}
+
+func TestLCM_Receiver_BPFFallbackOutOfMemory(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("BPF programs are only set on Linux")
+	}
+	// given channels that assemble into a BPF program within the instruction limit, but too large for the socket
+	// option memory limit of the kernel
+	var channels []string
+	for i := range 200 {
+		channels = append(channels, fmt.Sprintf("vehicle.sensor.%d.measurement", i))
+	}
+	program, err := lcmbpf.Assemble(lcmbpf.Channel(channels...))
+	assert.NilError(t, err)
+	assert.Assert(t, len(program) <= lcmbpf.MaxInstructions)
+	for _, tt := range []struct {
+		name   string
+		listen func(context.Context, int, ...ReceiverOption) (*Receiver, error)
+	}{
+		{
+			name: "listen",
+			listen: func(ctx context.Context, port int, opts ...ReceiverOption) (*Receiver, error) {
+				return ListenUDP(ctx, append(opts, WithReceivePort(port), WithReceiveChannels(channels...))...)
+			},
+		},
+		{
+			name: "add channels",
+			listen: func(ctx context.Context, port int, opts ...ReceiverOption) (*Receiver, error) {
+				rx, err := ListenUDP(ctx, append(opts, WithReceivePort(port), WithReceiveChannels(channels[0]))...)
+				if err != nil {
+					return nil, err
+				}
+				return rx, rx.AddChannels(ctx, channels[1:]...)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			const testTimeout = 1 * time.Second
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			freePort := getFreePort(t)
+			var metrics recordingMetrics
+			// when the receiver filters the channels
+			rx, err := tt.listen(ctx, freePort, WithReceiveMetrics(&metrics))
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, rx.Close())
+			}()
+			if !slices.ContainsFunc(metrics.events(), func(event string) bool {
+				return strings.HasPrefix(event, "BPFFallback ")
+			}) {
+				t.Skip("the kernel accepted the BPF program")
+			}
+			// then the receiver should fall back to receiving all messages, and filter them in user space
+			tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, tx.Close())
+			}()
+			assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+			assert.NilError(t, tx.Transmit(ctx, channels[199], []byte("bar")))
+			assert.NilError(t, rx.Receive(ctx))
+			assert.Equal(t, channels[199], rx.Message().Channel)
+		})
+	}
+}
+
+func TestLCM_OneTransmitter_OneReceiver_Metrics(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	var rxMetrics, txMetrics recordingMetrics
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveBPF(nil), WithReceiveMetrics(&rxMetrics))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}
+	tx, err := DialUDP(ctx, WithTransmitAddress(addr), WithTransmitMetrics(&txMetrics))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when valid, malformed, undecodable and untransmittable messages are transmitted
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	_, err = tx.conn.WriteTo([]byte("garbage"), nil, addr)
+	assert.NilError(t, err)
+	assert.NilError(t, tx.TransmitMessage(ctx, &Message{Channel: "compressed", Params: "z=lz4", Data: []byte("bar")}))
+	assert.NilError(t, tx.Transmit(ctx, "google.protobuf.Timestamp", []byte{0xff}))
+	assert.Assert(t, tx.Transmit(ctx, strings.Repeat("x", lengthOfLongestChannel+1), nil) != nil)
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Assert(t, rx.Receive(ctx) != nil)
+	assert.Assert(t, rx.Receive(ctx) != nil)
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Assert(t, rx.UnmarshalProto(ctx, &timestamppb.Timestamp{}) != nil)
+	// then the receiver and the transmitter should report the events to the metrics
+	assert.DeepEqual(
+		t,
+		[]string{
+			"MessageReceived foo",
+			"DecodeFailed ",
+			"DecompressFailed compressed",
+			"MessageReceived google.protobuf.Timestamp",
+			"DecodeFailed google.protobuf.Timestamp",
+		},
+		rxMetrics.events(),
+	)
+	assert.DeepEqual(
+		t,
+		[]string{
+			"MessageTransmitted foo",
+			"MessageTransmitted compressed",
+			"MessageTransmitted google.protobuf.Timestamp",
+			"TransmitFailed " + strings.Repeat("x", lengthOfLongestChannel+1),
+		},
+		txMetrics.events(),
+	)
+}
+
+func TestLCM_OneTransmitter_OneReceiver_NilMetricsAndLogger(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	// when the receiver and the transmitter are configured with nil metrics and loggers
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveMetrics(nil), WithReceiveLogger(nil))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}),
+		WithTransmitMetrics(nil),
+		WithTransmitLogger(nil),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// then transmitting and receiving should not panic
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "foo", rx.Message().Channel)
+}
+
+// recordingMetrics records the instrumentation events of a receiver or a transmitter.
+type recordingMetrics struct {
+	mu     sync.Mutex
+	record []string
+}
+
+func (m *recordingMetrics) add(event, channel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record = append(m.record, event+" "+channel)
+}
+
+func (m *recordingMetrics) events() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.record)
+}
+
+func (m *recordingMetrics) MessageReceived(channel string, _ int) { m.add("MessageReceived", channel) }
+func (m *recordingMetrics) DecodeFailed(channel string)           { m.add("DecodeFailed", channel) }
+func (m *recordingMetrics) DecompressFailed(channel string)       { m.add("DecompressFailed", channel) }
+func (m *recordingMetrics) BPFFallback(instructions int) {
+	m.add("BPFFallback", strconv.Itoa(instructions))
+}
+func (m *recordingMetrics) TransmitFailed(channel string) { m.add("TransmitFailed", channel) }
+func (m *recordingMetrics) MessageTransmitted(channel string, _ int, _ time.Duration) {
+	m.add("MessageTransmitted", channel)
+}
+
+func TestLCM_OneTransmitter_OneReceiver_Malformed(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		skipMalformed bool
+	}{
+		{name: "return malformed"},
+		{name: "skip malformed", skipMalformed: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			const testTimeout = 1 * time.Second
+			ip := net.IPv4(239, 0, 0, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			freePort := getFreePort(t)
+			ifi := getInterface(t)
+			rx, err := ListenMulticastUDP(
+				ctx,
+				WithReceiveInterface(ifi.Name),
+				WithReceivePort(freePort),
+				WithReceiveAddress(ip),
+				WithReceiveBPF(nil),
+				WithReceiveSkipMalformed(tt.skipMalformed),
+			)
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, rx.Close())
+			}()
+			tx, err := DialMulticastUDP(
+				ctx,
+				WithTransmitInterface(ifi.Name),
+				WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+			)
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, tx.Close())
+			}()
+			// when a malformed datagram is transmitted before a valid message
+			_, err = tx.conn.WriteTo([]byte("garbage"), nil, &net.UDPAddr{IP: ip, Port: freePort})
+			assert.NilError(t, err)
+			assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+			// then the receiver should either return or skip the malformed datagram
+			err = rx.Receive(ctx)
+			if !tt.skipMalformed {
+				var malformedErr *MalformedMessageError
+				assert.Assert(t, errors.As(err, &malformedErr))
+				assert.ErrorIs(t, err, ErrInsufficientData)
+				assert.NilError(t, rx.Receive(ctx))
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, "foo", rx.Message().Channel)
+			assert.Equal(t, uint64(1), rx.Stats().Malformed)
+		})
+	}
+}
+
+func TestLCM_OneTransmitter_OneReceiver_ChannelPatterns(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	rx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveAddress(ip),
+		WithReceiveChannels("foo.*", "vehicle.*.state"),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits on matching and non-matching channels
+	for _, channel := range []string{"bar", "vehicle.front.status", "foo.bar", "vehicle.front.state"} {
+		assert.NilError(t, tx.Transmit(ctx, channel, []byte("data")))
+	}
+	// then the receiver should only receive the matching channels
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "foo.bar", rx.Message().Channel)
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "vehicle.front.state", rx.Message().Channel)
+}
+
+func TestLCM_OneTransmitter_OneReceiver_ChangeChannels(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	rx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveAddress(ip),
+		WithReceiveChannels("foo"),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	t.Run("add channels", func(t *testing.T) {
+		// when the receiver subscribes to an additional channel
+		assert.NilError(t, rx.AddChannels(ctx, "bar.*"))
+		assert.NilError(t, tx.Transmit(ctx, "baz", []byte("data")))
+		assert.NilError(t, tx.Transmit(ctx, "bar.baz", []byte("data")))
+		// then the receiver should receive the added channel
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "bar.baz", rx.Message().Channel)
+	})
+	t.Run("remove channels", func(t *testing.T) {
+		// when the receiver unsubscribes from a channel
+		assert.NilError(t, rx.RemoveChannels(ctx, "foo"))
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+		assert.NilError(t, tx.Transmit(ctx, "bar.baz", []byte("data")))
+		// then the receiver should no longer receive the removed channel
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "bar.baz", rx.Message().Channel)
+	})
+	t.Run("add protos", func(t *testing.T) {
+		// when the receiver subscribes to a proto message
+		assert.NilError(t, rx.AddProtos(ctx, &timestamppb.Timestamp{}))
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 1}))
+		// then the receiver should receive the proto message
+		assert.NilError(t, rx.ReceiveProto(ctx))
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1}, rx.ProtoMessage(), protocmp.Transform())
+	})
+	t.Run("remove protos", func(t *testing.T) {
+		// when the receiver unsubscribes from a proto message
+		assert.NilError(t, rx.RemoveProtos(ctx, &timestamppb.Timestamp{}))
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 2}))
+		assert.NilError(t, tx.Transmit(ctx, "bar.baz", []byte("data")))
+		// then the receiver should no longer receive the proto message
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "bar.baz", rx.Message().Channel)
+	})
+	t.Run("remove protos subscribed as channels", func(t *testing.T) {
+		// when the receiver unsubscribes from a proto message also subscribed to as a channel
+		assert.NilError(t, rx.AddChannels(ctx, "google.protobuf.Timestamp"))
+		assert.NilError(t, rx.AddProtos(ctx, &timestamppb.Timestamp{}))
+		assert.NilError(t, rx.RemoveProtos(ctx, &timestamppb.Timestamp{}))
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 3}))
+		// then the receiver should still receive the channel
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "google.protobuf.Timestamp", rx.Message().Channel)
+	})
+	t.Run("remove all channels", func(t *testing.T) {
+		// when the receiver unsubscribes from all channels
+		assert.NilError(t, rx.RemoveChannels(ctx, "bar.*", "google.protobuf.Timestamp"))
+		assert.NilError(t, tx.Transmit(ctx, "baz", []byte("data")))
+		assert.NilError(t, tx.Transmit(ctx, "bar.baz", []byte("data")))
+		assert.NilError(t, rx.AddChannels(ctx, "qux"))
+		assert.NilError(t, tx.Transmit(ctx, "qux", []byte("data")))
+		// then the receiver should receive no channels until a channel is added
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "qux", rx.Message().Channel)
+	})
+}
+
+func TestLCM_OneTransmitter_ReceiverGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("receiver groups are only supported on Linux")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	group, err := ListenMulticastUDPGroup(
+		ctx,
+		3,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveAddress(ip),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, group.Close())
+	}()
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	const channels, messagesPerChannel = 20, 5
+	// when the transmitter transmits on many channels
+	for i := 0; i < messagesPerChannel; i++ {
+		for j := 0; j < channels; j++ {
+			assert.NilError(t, tx.Transmit(ctx, fmt.Sprintf("channel.%d", j), []byte{byte(i)}))
+		}
+	}
+	// then each channel should be received in order by a single receiver
+	var mu sync.Mutex
+	receivers := make(map[string]*Receiver)
+	received := make(map[string][]byte)
+	var total int
+	receiveCtx, cancelReceive := context.WithCancel(ctx)
+	defer cancelReceive()
+	err = group.Receive(receiveCtx, func(_ context.Context, rx *Receiver) error {
+		mu.Lock()
+		defer mu.Unlock()
+		channel := rx.Message().Channel
+		if receiver, ok := receivers[channel]; ok && receiver != rx {
+			return fmt.Errorf("channel %s received by multiple receivers", channel)
+		}
+		receivers[channel] = rx
+		received[channel] = append(received[channel], rx.Message().Data...)
+		if total++; total == channels*messagesPerChannel {
+			cancelReceive()
+		}
+		return nil
+	})
+	assert.Assert(t, errors.Is(err, context.Canceled), err)
+	assert.Equal(t, channels, len(received))
+	for channel, data := range received {
+		assert.DeepEqual(t, []byte{0, 1, 2, 3, 4}, data)
+		assert.Equal(t, group.Receivers()[lcmbpf.ChannelHash(channel)%3], receivers[channel])
+	}
+}
+
+func TestLCM_OneTransmitter_OneReceiver_Interfaces(t *testing.T) {
+	ifi := getInterface(t)
+	for _, tt := range []struct {
+		name string
+		opts []ReceiverOption
+	}{
+		{
+			name: "all interfaces",
+			opts: []ReceiverOption{WithReceiveAllInterfaces(), WithReceiveInterfaceRefresh(10 * time.Millisecond)},
+		},
+		{
+			name: "repeated interface",
+			opts: []ReceiverOption{WithReceiveInterface(ifi.Name), WithReceiveInterface(ifi.Name)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// setup
+			const testTimeout = 1 * time.Second
+			ip := net.IPv4(239, 0, 0, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			freePort := getFreePort(t)
+			rx, err := ListenMulticastUDP(
+				ctx,
+				append(tt.opts, WithReceivePort(freePort), WithReceiveAddress(ip))...,
+			)
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, rx.Close())
+				assert.NilError(t, rx.Close()) // closing twice is a no-op
+			}()
+			tx, err := DialMulticastUDP(
+				ctx,
+				WithTransmitInterface(ifi.Name),
+				WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+			)
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, tx.Close())
+			}()
+			// when the transmitter transmits on the interface
+			assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+			// then the receiver should receive the message on the interface
+			assert.NilError(t, rx.Receive(ctx))
+			assert.Equal(t, "foo", rx.Message().Channel)
+			assert.Equal(t, ifi.Index, rx.InterfaceIndex())
+		})
+	}
+}
+
+func TestLCM_MultiInterfaceTransmitter_OneReceiver(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	rx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveAddress(ip),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	// the same interface is provided twice, since the test environment may only have a single multicast interface
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits a message
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+	// then the receiver should receive the message once per interface
+	for i := 0; i < 2; i++ {
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "foo", rx.Message().Channel)
+		assert.Equal(t, uint32(0), rx.Message().SequenceNumber)
+		assert.Equal(t, ifi.Index, rx.InterfaceIndex())
+	}
+	assert.Equal(t, uint64(1), rx.Stats().Duplicates)
+}
+
+func TestLCM_OneTransmitter_SourceSpecificReceivers(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(232, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	source := getInterfaceIPv4(t, ifi)
+	trustedRx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveSource(ip, source),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, trustedRx.Close())
+	}()
+	untrustedRx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveSource(ip, net.IPv4(192, 0, 2, 1)),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, untrustedRx.Close())
+	}()
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits a message
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+	// then the receiver of the transmitter's source should receive the message
+	assert.NilError(t, trustedRx.Receive(ctx))
+	assert.Equal(t, "foo", trustedRx.Message().Channel)
+	// and the receiver of another source should not
+	receiveCtx, cancelReceive := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelReceive()
+	assert.Assert(t, untrustedRx.Receive(receiveCtx) != nil)
+}
+
+func TestLCM_OneTransmitter_OneReceiver_BindAddress(t *testing.T) {
+	t.Run("multicast", func(t *testing.T) {
+		// setup
+		const testTimeout = 1 * time.Second
+		ip1, ip2 := net.IPv4(239, 0, 0, 1), net.IPv4(239, 0, 0, 2)
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		freePort := getFreePort(t)
+		ifi := getInterface(t)
+		rx, err := ListenMulticastUDP(
+			ctx,
+			WithReceiveInterface(ifi.Name),
+			WithReceivePort(freePort),
+			WithReceiveBindAddress(ip1),
+			WithReceiveAddress(ip1),
+			WithReceiveAddress(ip2),
+		)
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, rx.Close())
+		}()
+		tx, err := DialMulticastUDP(
+			ctx,
+			WithTransmitInterface(ifi.Name),
+			WithTransmitAddress(&net.UDPAddr{IP: ip2, Port: freePort}),
+			WithTransmitAddress(&net.UDPAddr{IP: ip1, Port: freePort}),
+		)
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, tx.Close())
+		}()
+		// when the transmitter transmits to both groups
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+		assert.NilError(t, tx.Transmit(ctx, "bar", []byte("data")))
+		// then the receiver should only receive the messages to the bound group
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "foo", rx.Message().Channel)
+		assert.Assert(t, ip1.Equal(rx.DestinationAddress()))
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "bar", rx.Message().Channel)
+		assert.Assert(t, ip1.Equal(rx.DestinationAddress()))
+	})
+	t.Run("unspecified", func(t *testing.T) {
+		// setup
+		const testTimeout = 1 * time.Second
+		ip := net.IPv4(239, 0, 0, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		freePort := getFreePort(t)
+		ifi := getInterface(t)
+		// when multiple receivers are bound to the unspecified address on the same port
+		var rxs []*Receiver
+		for range 2 {
+			rx, err := ListenMulticastUDP(
+				ctx,
+				WithReceiveInterface(ifi.Name),
+				WithReceivePort(freePort),
+				WithReceiveBindAddress(net.IPv4zero),
+				WithReceiveAddress(ip),
+			)
+			assert.NilError(t, err)
+			defer func() {
+				assert.NilError(t, rx.Close())
+			}()
+			rxs = append(rxs, rx)
+		}
+		tx, err := DialMulticastUDP(
+			ctx,
+			WithTransmitInterface(ifi.Name),
+			WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+		)
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, tx.Close())
+		}()
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+		// then all receivers should receive the message
+		for _, rx := range rxs {
+			assert.NilError(t, rx.Receive(ctx))
+			assert.Equal(t, "foo", rx.Message().Channel)
+		}
+	})
+	t.Run("unicast", func(t *testing.T) {
+		// setup
+		const testTimeout = 1 * time.Second
+		ip := net.IPv4(127, 0, 0, 1)
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		freePort := getFreePort(t)
+		rx, err := ListenMulticastUDP(ctx, WithReceivePort(freePort), WithReceiveBindAddress(ip))
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, rx.Close())
+		}()
+		tx, err := DialMulticastUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}))
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, tx.Close())
+		}()
+		// when the transmitter transmits to the bound unicast address
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+		// then the receiver should receive the message
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "foo", rx.Message().Channel)
+	})
+}
+
+func TestLCM_UnicastTransmitter_UnicastReceiver(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveProtos(&timestamppb.Timestamp{}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}),
+		WithTransmitCompressionProto(lcmlz4.NewCompressor(), &timestamppb.Timestamp{}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits a compressed proto message
+	assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}))
+	// then the receiver should receive the proto message
+	assert.NilError(t, rx.ReceiveProto(ctx))
+	assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, rx.ProtoMessage(), protocmp.Transform())
+}
+
+func TestLCM_BroadcastTransmitter_UnicastReceiver(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("broadcast is only supported on Linux")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	broadcast := getInterfaceBroadcastIPv4(t, getInterface(t))
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: broadcast, Port: freePort}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits to the subnet broadcast address
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+	// then the receiver should receive the message
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "foo", rx.Message().Channel)
+	assert.Assert(t, broadcast.Equal(rx.DestinationAddress()))
+}
+
+func TestLCM_OneTransmitter_OneReceiver_ManyChannels(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	channels := make([]string, 0, 50)
+	for i := 0; i < cap(channels); i++ {
+		channels = append(channels, fmt.Sprintf("vehicle.sensors.%d", i))
+	}
+	var logs bytes.Buffer
+	rx, err := ListenMulticastUDP(
+		ctx,
+		WithReceiveInterface(ifi.Name),
+		WithReceivePort(freePort),
+		WithReceiveAddress(ip),
+		WithReceiveChannels(channels...),
+		WithReceiveLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	// then the BPF program should be set
+	assert.Assert(t, !strings.Contains(logs.String(), "skipped BPF program"), logs.String())
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits on subscribed and unsubscribed channels
+	assert.NilError(t, tx.Transmit(ctx, "vehicle.sensors.50", []byte("data")))
+	assert.NilError(t, tx.Transmit(ctx, "vehicle.sensors.42", []byte("data")))
+	// then the receiver should only receive the subscribed channel
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "vehicle.sensors.42", rx.Message().Channel)
+	// and the unsubscribed channel should have been filtered by the kernel
+	assert.Equal(t, uint64(1), rx.Stats().Received)
+}
+
+func getInterface(t *testing.T) *net.Interface {
+	t.Helper()
+	ifi, err := nettest.RoutedInterface("ip4", net.FlagUp|net.FlagMulticast|net.FlagLoopback)
+	if err == nil {
+		return ifi
+	}
+	ifi, err = nettest.RoutedInterface("ip4", net.FlagUp|net.FlagMulticast)
+	assert.NilError(t, err)
+	return ifi
+}
+
+func getInterfaceIPv4(t *testing.T, ifi *net.Interface) net.IP {
+	t.Helper()
+	addrs, err := ifi.Addrs()
+	assert.NilError(t, err)
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4()
+		}
+	}
+	t.Fatalf("no IPv4 address on interface %s", ifi.Name)
+	return nil
+}
+
+func getInterfaceBroadcastIPv4(t *testing.T, ifi *net.Interface) net.IP {
+	t.Helper()
+	addrs, err := ifi.Addrs()
+	assert.NilError(t, err)
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && ipNet.IP.IsGlobalUnicast() {
+			broadcast := make(net.IP, net.IPv4len)
+			for i, b := range ipNet.IP.To4() {
+				broadcast[i] = b | ^ipNet.Mask[len(ipNet.Mask)-net.IPv4len+i]
+			}
+			return broadcast
+		}
+	}
+	t.Skipf("no broadcast address on interface %s", ifi.Name)
+	return nil
+}
+
+// countOpenFiles returns the number of open file descriptors of the process, on Linux.
+func countOpenFiles(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	assert.NilError(t, err)
+	return len(entries)
+}
+
+func getFreePort(t *testing.T) int {
+	t.Helper()
+	l, err := nettest.NewLocalPacketListener("udp4")
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, l.Close())
+	}()
+	return l.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestLCM_UnixgramTransmitter_UnixgramReceivers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix datagram sockets are not supported on Windows")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	dir := t.TempDir()
+	rx1, err := ListenUnixgram(ctx, dir, WithReceiveProtos(&timestamppb.Timestamp{}), WithReceiveChannels("foo"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx1.Close())
+	}()
+	rx2, err := ListenUnixgram(ctx, dir, WithReceiveChannels("foo"), WithReceiveSocketMode(0o600))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx2.Close())
+	}()
+	// a socket left behind by a receiver that is gone
+	stale, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filepath.Join(dir, "stale.sock"), Net: "unixgram"})
+	assert.NilError(t, err)
+	assert.NilError(t, stale.Close())
+	tx, err := DialUnixgram(ctx, dir, WithTransmitCompressionProto(lcmlz4.NewCompressor(), &timestamppb.Timestamp{}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits a compressed proto message and a raw message
+	assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}))
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	// then the first receiver should receive both messages
+	assert.NilError(t, rx1.ReceiveProto(ctx))
+	assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, rx1.ProtoMessage(), protocmp.Transform())
+	assert.NilError(t, rx1.Receive(ctx))
+	assert.DeepEqual(t, &Message{Channel: "foo", SequenceNumber: 1, Data: []byte("bar")}, rx1.Message())
+	// and the second receiver should only receive the message on its channel
+	assert.NilError(t, rx2.Receive(ctx))
+	assert.DeepEqual(t, &Message{Channel: "foo", SequenceNumber: 1, Data: []byte("bar")}, rx2.Message())
+	info, err := os.Stat(rx2.unixPath)
+	assert.NilError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	assert.Equal(t, dir, filepath.Dir(rx2.unixPath))
+	entries, err := os.ReadDir(dir)
+	assert.NilError(t, err)
+	for _, entry := range entries {
+		assert.Assert(t, !entry.IsDir(), "private directory %s left behind", entry.Name())
+	}
+	if runtime.GOOS == "linux" {
+		// when another transmitter transmits to the receivers
+		tx2, err := DialUnixgram(ctx, dir)
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, tx2.Close())
+		}()
+		assert.NilError(t, tx2.Transmit(ctx, "foo", []byte("baz")))
+		assert.NilError(t, rx2.Receive(ctx))
+		// then the transmitters should be told apart by their socket paths
+		senders := rx2.Stats().Senders
+		assert.Equal(t, 2, len(senders))
+		assert.Assert(t, strings.HasPrefix(senders[0].Path, "@lcm."))
+		assert.Assert(t, strings.HasPrefix(senders[1].Path, "@lcm."))
+		assert.Assert(t, senders[0].Path != senders[1].Path)
+	}
+}
+
+func TestLCM_UnixgramTransmitter_UnwritableReceiver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix datagram sockets are not supported on Windows")
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("socket permissions are not enforced for root")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	dir := t.TempDir()
+	unwritable, err := ListenUnixgram(ctx, dir, WithReceiveSocketMode(0o400))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, unwritable.Close())
+	}()
+	rx, err := ListenUnixgram(ctx, dir)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUnixgram(ctx, dir)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits to a receiver socket it can not write to
+	for range 2 {
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	}
+	// then the other receiver should still receive the messages
+	for i := range 2 {
+		assert.NilError(t, rx.Receive(ctx))
+		assert.DeepEqual(t, &Message{Channel: "foo", SequenceNumber: uint32(i), Data: []byte("bar")}, rx.Message())
+	}
+}
+
+func TestLCM_SharedMemoryTransmitter_SharedMemoryReceiver(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("shared memory is only supported on Linux")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "lcm-test")
+	tx, err := DialSharedMemory(
+		ctx,
+		path,
+		WithTransmitSharedMemorySize(1<<20),
+		WithTransmitCompressionProto(lcmlz4.NewCompressor(), &timestamppb.Timestamp{}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	rx, err := ListenSharedMemory(ctx, path, WithReceiveChannels("*"), WithReceiveProtos(&timestamppb.Timestamp{}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	t.Run("large message", func(t *testing.T) {
+		data := bytes.Repeat([]byte{0xab}, 256<<10)
+		assert.NilError(t, tx.Transmit(ctx, "camera", data))
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, "camera", rx.Message().Channel)
+		assert.Assert(t, bytes.Equal(data, rx.Message().Data))
+	})
+	t.Run("wrap around", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			data := bytes.Repeat([]byte{byte(i)}, 100<<10)
+			assert.NilError(t, tx.Transmit(ctx, "lidar", data))
+			assert.NilError(t, rx.Receive(ctx))
+			assert.Equal(t, "lidar", rx.Message().Channel)
+			assert.Assert(t, bytes.Equal(data, rx.Message().Data))
+		}
+		assert.Equal(t, uint64(0), rx.Stats().Discarded)
+	})
+	t.Run("blocking receive", func(t *testing.T) {
+		var g errgroup.Group
+		g.Go(func() error {
+			return rx.Receive(ctx)
+		})
+		time.Sleep(10 * time.Millisecond)
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("data")))
+		assert.NilError(t, g.Wait())
+		assert.Equal(t, "foo", rx.Message().Channel)
+	})
+	t.Run("compressed proto", func(t *testing.T) {
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}))
+		assert.NilError(t, rx.ReceiveProto(ctx))
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, rx.ProtoMessage(), protocmp.Transform())
+	})
+	t.Run("raw", func(t *testing.T) {
+		assert.NilError(t, tx.TransmitRaw(ctx, []byte("LC02\x00\x00\x00\x07foo\x00data")))
+		assert.NilError(t, rx.ReceiveRaw(ctx))
+		assert.DeepEqual(t, &Message{Channel: "foo", SequenceNumber: 7, Data: []byte("data")}, rx.Message())
+	})
+	t.Run("dropped", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			assert.NilError(t, tx.Transmit(ctx, "lidar", bytes.Repeat([]byte{byte(i)}, 100<<10)))
+		}
+		// then the receiver should skip the overwritten messages
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, byte(99), rx.Message().Data[0])
+		assert.Equal(t, uint64(99), rx.Stats().Discarded)
+	})
+	t.Run("invalid record", func(t *testing.T) {
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("corrupted")))
+		// when the record is corrupted, such as by a crashed transmitter
+		position := tx.shm.last().Load() % tx.shm.capacity
+		binary.NativeEndian.PutUint32(tx.shm.data[position+offsetShmRecordLength:], 3)
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("valid")))
+		// then the receiver should skip to the next record, and count the invalid record as dropped
+		assert.NilError(t, rx.Receive(ctx))
+		assert.DeepEqual(t, []byte("valid"), rx.Message().Data)
+		assert.Equal(t, uint64(100), rx.Stats().Discarded)
+	})
+	t.Run("too long", func(t *testing.T) {
+		assert.ErrorContains(t, tx.Transmit(ctx, "foo", make([]byte, 1<<19)), "too long")
+	})
+	t.Run("timeout", func(t *testing.T) {
+		timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancelTimeout()
+		assert.ErrorIs(t, rx.Receive(timeoutCtx), context.DeadlineExceeded)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		cancelCtx, cancelReceive := context.WithCancel(ctx)
+		time.AfterFunc(10*time.Millisecond, cancelReceive)
+		assert.ErrorIs(t, rx.Receive(cancelCtx), context.Canceled)
+	})
+	t.Run("single transmitter", func(t *testing.T) {
+		_, err := DialSharedMemory(ctx, path)
+		assert.ErrorContains(t, err, "already has a transmitter")
+	})
+	t.Run("no transmitter", func(t *testing.T) {
+		_, err := ListenSharedMemory(ctx, filepath.Join(t.TempDir(), "lcm-test"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestLCM_SharedMemoryTransmitter_UninitializedFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("shared memory is only supported on Linux")
+	}
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	// given an uninitialized file smaller than the ring buffer
+	path := filepath.Join(t.TempDir(), "lcm-test")
+	assert.NilError(t, os.WriteFile(path, make([]byte, 4096), 0o600))
+	// when a transmitter is dialed on the file
+	tx, err := DialSharedMemory(ctx, path, WithTransmitSharedMemorySize(1<<20))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// then the file should be grown to fit the ring buffer
+	info, err := os.Stat(path)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(lengthOfShmHeader+1<<20), info.Size())
+	rx, err := ListenSharedMemory(ctx, path)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	assert.NilError(t, tx.Transmit(ctx, "foo", bytes.Repeat([]byte{0xab}, 256<<10)))
+	assert.NilError(t, rx.Receive(ctx))
+	assert.Equal(t, "foo", rx.Message().Channel)
+}
+
+func TestLCM_OneTransmitter_OneReceiver_TransmitMessage(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ip := net.IPv4(239, 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	ifi := getInterface(t)
+	rx, err := ListenMulticastUDP(ctx, WithReceiveInterface(ifi.Name), WithReceivePort(freePort), WithReceiveAddress(ip))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialMulticastUDP(
+		ctx,
+		WithTransmitInterface(ifi.Name),
+		WithTransmitAddress(&net.UDPAddr{IP: ip, Port: freePort}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	compressed, err := lcmlz4.NewCompressor().Compress([]byte(strings.Repeat("foo", 100)))
+	assert.NilError(t, err)
+	// when the transmitter transmits an already compressed message with its own sequence number
+	assert.NilError(t, tx.TransmitMessage(ctx, &Message{
+		Channel:        "forwarded",
+		Params:         "z=lz4",
+		SequenceNumber: 42,
+		Data:           compressed,
+	}))
+	// then the receiver should receive the message as transmitted
+	assert.NilError(t, rx.Receive(ctx))
+	assert.DeepEqual(t, &Message{
+		Channel:        "forwarded",
+		Params:         "z=lz4",
+		SequenceNumber: 42,
+		Data:           []byte(strings.Repeat("foo", 100)),
+	}, rx.Message())
+	// and the sequence number of the transmitter should be unchanged
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	assert.NilError(t, rx.Receive(ctx))
+	assert.DeepEqual(t, &Message{Channel: "foo", Data: []byte("bar")}, rx.Message())
+}
+
+func TestLCM_Relay_Raw(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	relayPort, receiverPort := getFreePort(t), getFreePort(t)
+	relayRx, err := ListenUDP(ctx, WithReceivePort(relayPort))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, relayRx.Close())
+	}()
+	relayTx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: receiverPort}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, relayTx.Close())
+	}()
+	rx, err := ListenUDP(ctx, WithReceivePort(receiverPort))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: relayPort}),
+		WithTransmitCompression(lcmlz4.NewCompressor(), "foo"),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	data := []byte(strings.Repeat("bar", 100))
+	// when the transmitter transmits a compressed message
+	assert.NilError(t, tx.Transmit(ctx, "foo", data))
+	// then the relay should receive the message without decompressing it
+	assert.NilError(t, relayRx.ReceiveRaw(ctx))
+	assert.Equal(t, "foo", relayRx.Message().Channel)
+	assert.Equal(t, "z=lz4", relayRx.Message().Params)
+	assert.Assert(t, !bytes.Equal(data, relayRx.Message().Data))
+	assert.Equal(t, "LC02", string(relayRx.RawMessage()[:4]))
+	// and when the relay forwards the datagram
+	assert.NilError(t, relayTx.TransmitRaw(ctx, relayRx.RawMessage()))
+	// then the receiver should receive the original message
+	assert.NilError(t, rx.Receive(ctx))
+	assert.DeepEqual(t, &Message{Channel: "foo", Params: "z=lz4", Data: data}, rx.Message())
+	// and datagrams that are not LCM messages should not be forwarded
+	assert.ErrorIs(t, relayTx.TransmitRaw(ctx, []byte("not an LCM message")), ErrWrongHeaderMagic)
+}
+
+func TestLCM_OneTransmitter_OneReceiver_Owned(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}),
+		WithTransmitCompression(lcmlz4.NewCompressor(), "foo"),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits compressed messages, which share the decompression buffer when received
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte(strings.Repeat("first", 100))))
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte(strings.Repeat("second", 100))))
+	// then the owned messages should not be overwritten by later messages
+	first, err := rx.ReceiveOwned(ctx)
+	assert.NilError(t, err)
+	defer first.Release()
+	second, err := rx.ReceiveOwned(ctx)
+	assert.NilError(t, err)
+	defer second.Release()
+	assert.DeepEqual(t, []byte(strings.Repeat("first", 100)), first.Data)
+	assert.DeepEqual(t, []byte(strings.Repeat("second", 100)), second.Data)
+	assert.Equal(t, uint32(1), second.SequenceNumber)
+}
+
+func TestLCM_ProtoTransmitter_ProtoReceiver_Unmarshal(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveProtos(&timestamppb.Timestamp{}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	t.Run("caller-provided", func(t *testing.T) {
+		// when the transmitter transmits proto messages
+		var history []*timestamppb.Timestamp
+		for i := int32(0); i < 3; i++ {
+			assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Nanos: i}))
+		}
+		// then each message should be decoded into its own caller-provided message
+		for range 3 {
+			assert.NilError(t, rx.Receive(ctx))
+			var m timestamppb.Timestamp
+			assert.NilError(t, rx.UnmarshalProto(ctx, &m))
+			history = append(history, &m)
+		}
+		assert.DeepEqual(t, []*timestamppb.Timestamp{{Nanos: 0}, {Nanos: 1}, {Nanos: 2}}, history, protocmp.Transform())
+	})
+	t.Run("reset and merge", func(t *testing.T) {
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Nanos: 2}))
+		assert.NilError(t, rx.Receive(ctx))
+		reset := &timestamppb.Timestamp{Seconds: 1}
+		assert.NilError(t, rx.UnmarshalProto(ctx, reset))
+		assert.DeepEqual(t, &timestamppb.Timestamp{Nanos: 2}, reset, protocmp.Transform())
+		merged := &timestamppb.Timestamp{Seconds: 1}
+		assert.NilError(t, rx.UnmarshalProto(ctx, merged, WithUnmarshalMerge(true)))
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, merged, protocmp.Transform())
+	})
+	t.Run("pooled", func(t *testing.T) {
+		var pool ProtoPool
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}))
+		assert.NilError(t, rx.Receive(ctx))
+		m, err := rx.NewProto(ctx, WithUnmarshalPool(&pool))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, m, protocmp.Transform())
+		pool.Put(m)
+		// and messages drawn from the pool should be reset
+		assert.DeepEqual(
+			t, &timestamppb.Timestamp{}, pool.Get((&timestamppb.Timestamp{}).ProtoReflect().Type()), protocmp.Transform(),
+		)
+	})
+	t.Run("unregistered", func(t *testing.T) {
+		assert.NilError(t, rx.AddChannels(ctx, "foo"))
+		assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+		assert.NilError(t, rx.Receive(ctx))
+		m, err := rx.NewProto(ctx)
+		assert.ErrorIs(t, err, ErrUnregisteredProto)
+		assert.ErrorIs(t, err, protoregistry.NotFound)
+		assert.Assert(t, m == nil)
+	})
+}
+
+func TestLCM_ProtoTransmitter_ProtoReceiver_MultipleChannels(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(
+		ctx,
+		WithReceivePort(freePort),
+		WithReceiveProtos(&timestamppb.Timestamp{}),
+		WithReceiveChannels("camera.front"),
+		WithReceiveProtoOnChannel("camera.rear", &durationpb.Duration{}),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}),
+		WithTransmitCompression(lcmlz4.NewCompressor(), "camera.front"),
+		WithTransmitProtoTypeParam(true),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	t.Run("type param", func(t *testing.T) {
+		// when the transmitter transmits a compressed proto message on a channel other than its full name
+		assert.NilError(t, tx.TransmitProtoOnChannel(ctx, "camera.front", &timestamppb.Timestamp{Seconds: 1}))
+		// then the receiver should decode the message from the type param
+		assert.NilError(t, rx.ReceiveProto(ctx))
+		assert.Equal(t, "camera.front", rx.Message().Channel)
+		assert.Equal(t, "z=lz4&t=google.protobuf.Timestamp", rx.Message().Params)
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1}, rx.ProtoMessage(), protocmp.Transform())
+	})
+	t.Run("full name channel", func(t *testing.T) {
+		// when the transmitter transmits a proto message on its full name
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 2}))
+		// then no type param should be transmitted
+		assert.NilError(t, rx.ReceiveProto(ctx))
+		assert.Equal(t, "", rx.Message().Params)
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 2}, rx.ProtoMessage(), protocmp.Transform())
+	})
+	t.Run("channel type", func(t *testing.T) {
+		// when a proto message is transmitted without a type param
+		data, err := proto.Marshal(&durationpb.Duration{Seconds: 3})
+		assert.NilError(t, err)
+		assert.NilError(t, tx.Transmit(ctx, "camera.rear", data))
+		// then the receiver should decode the message from the type configured for the channel
+		assert.NilError(t, rx.ReceiveProto(ctx))
+		assert.DeepEqual(t, &durationpb.Duration{Seconds: 3}, rx.ProtoMessage(), protocmp.Transform())
+	})
+	t.Run("unsubscribed channel", func(t *testing.T) {
+		// when a proto message of a received type is transmitted on a channel that is not subscribed to
+		assert.NilError(t, tx.TransmitProtoOnChannel(ctx, "camera.side", &timestamppb.Timestamp{Seconds: 4}))
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 5}))
+		// then the receiver should filter it by its channel, regardless of its type param
+		assert.NilError(t, rx.ReceiveProto(ctx))
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 5}, rx.ProtoMessage(), protocmp.Transform())
+	})
+}
+
+func TestLCM_ProtoTransmitter_SchemaRegistry(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	toolPort, requestPort := getFreePort(t), getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(toolPort), WithReceiveChannels(SchemaChannel, "google.protobuf.Duration"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: toolPort}),
+		WithTransmitSchemas(0),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	registry := NewSchemaRegistry()
+	t.Run("first transmit", func(t *testing.T) {
+		// when the transmitter first transmits a proto message type
+		assert.NilError(t, tx.TransmitProto(ctx, &durationpb.Duration{Seconds: 1}))
+		// then the schema should be published before the message
+		assert.NilError(t, rx.Receive(ctx))
+		ok, err := registry.Observe(rx.Message())
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+		// and the registry should decode the message
+		assert.NilError(t, rx.Receive(ctx))
+		m, err := registry.Decode(rx.Message())
+		assert.NilError(t, err)
+		assert.DeepEqual(t, &durationpb.Duration{Seconds: 1}, m, protocmp.Transform())
+	})
+	t.Run("failed publish", func(t *testing.T) {
+		// when the schema of a proto message type fails to publish
+		expired, cancelExpired := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancelExpired()
+		assert.Assert(t, tx.TransmitProto(expired, &timestamppb.Timestamp{Seconds: 1}) != nil)
+		// then the schema should be published when the type is transmitted again
+		assert.NilError(t, tx.TransmitProto(ctx, &timestamppb.Timestamp{Seconds: 2}))
+		assert.NilError(t, rx.Receive(ctx))
+		ok, err := registry.Observe(rx.Message())
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+		_, err = registry.FindMessageByName("google.protobuf.Timestamp")
+		assert.NilError(t, err)
+	})
+	t.Run("request", func(t *testing.T) {
+		requestRx, err := ListenUDP(ctx, WithReceivePort(requestPort))
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, requestRx.Close())
+		}()
+		requestTx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: requestPort}))
+		assert.NilError(t, err)
+		defer func() {
+			assert.NilError(t, requestTx.Close())
+		}()
+		serveCtx, cancelServe := context.WithCancel(ctx)
+		var g errgroup.Group
+		g.Go(func() error {
+			return tx.ServeSchemaRequests(serveCtx, requestRx)
+		})
+		// when a tool requests the schemas
+		assert.NilError(t, requestTx.RequestSchemas(ctx, "google.protobuf.Duration"))
+		// then the transmitter should publish the schema
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, SchemaChannel, rx.Message().Channel)
+		cancelServe()
+		assert.ErrorIs(t, g.Wait(), context.Canceled)
+	})
+}
+
+func TestLCM_ProtoTransmitter_PeriodicSchemas(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveChannels(SchemaChannel))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}),
+		WithTransmitSchemas(10*time.Millisecond),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+		// closing a closed transmitter should not stop the background publishing twice
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits a proto message concurrently with the periodic schema publishing
+	assert.NilError(t, tx.TransmitProto(ctx, &durationpb.Duration{Seconds: 1}))
+	// then the schema should be published repeatedly
+	for range 3 {
+		assert.NilError(t, rx.Receive(ctx))
+		assert.Equal(t, SchemaChannel, rx.Message().Channel)
+	}
+}
+
+func TestLCM_AnnouncingTransmitter_Discovery(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort), WithReceiveChannels(DiscoveryChannel, "camera.*"))
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, rx.Close())
+	}()
+	tx, err := DialUDP(
+		ctx,
+		WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}),
+		WithTransmitAnnouncements("camera", 10*time.Millisecond),
+	)
+	assert.NilError(t, err)
+	defer func() {
+		assert.NilError(t, tx.Close())
+	}()
+	// when the transmitter transmits a proto message
+	assert.NilError(t, tx.TransmitProtoOnChannel(ctx, "camera.front", &timestamppb.Timestamp{}))
+	// then the discovery should find the node and its channels from the announcements
+	d := NewDiscovery(time.Second)
+	for {
+		assert.NilError(t, rx.Receive(ctx))
+		assert.NilError(t, d.Observe(rx))
+		if rx.Message().Channel != DiscoveryChannel || !strings.Contains(string(rx.Message().Data), "camera.front") {
+			continue
+		}
+		nodes := d.Nodes()
+		assert.Equal(t, 1, len(nodes))
+		hostname, err := os.Hostname()
+		assert.NilError(t, err)
+		assert.Equal(t, "camera", nodes[0].Node)
+		assert.Equal(t, hostname, nodes[0].Host)
+		assert.Equal(t, os.Getpid(), nodes[0].PID)
+		assert.DeepEqual(t, []AnnouncedChannel{{Name: "camera.front", Type: "google.protobuf.Timestamp"}}, nodes[0].Channels)
+		return
+	}
+}
+
+func TestLCM_Node(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
+	assert.NilError(t, err)
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	node := NewNode(rx, tx)
+	defer func() {
+		assert.NilError(t, node.Close())
+	}()
+	// given a node with a typed and a raw subscriber, and a publisher
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	received := make(chan *timestamppb.Timestamp, 1)
+	assert.NilError(t, SubscribeProto(ctx, node, "", func(_ context.Context, m *timestamppb.Timestamp) error {
+		received <- m
+		return nil
+	}))
+	var channels []string
+	assert.NilError(t, node.Subscribe(ctx, "camera.*", func(_ context.Context, m *Message) error {
+		channels = append(channels, m.Channel)
+		stop()
+		return nil
+	}))
+	publisher := NewPublisher[*timestamppb.Timestamp](node, "")
+	assert.DeepEqual(
+		t,
+		[]NodeChannel{{Name: "google.protobuf.Timestamp", Type: "google.protobuf.Timestamp"}},
+		node.Publications(),
+	)
+	assert.DeepEqual(
+		t,
+		[]NodeChannel{{Name: "camera.*"}, {Name: "google.protobuf.Timestamp", Type: "google.protobuf.Timestamp"}},
+		node.Subscriptions(),
+	)
+	var g errgroup.Group
+	g.Go(func() error {
+		return node.Run(runCtx)
+	})
+	// when the node publishes messages
+	expected := &timestamppb.Timestamp{Seconds: 1}
+	assert.NilError(t, publisher.Publish(ctx, expected))
+	assert.DeepEqual(t, expected, <-received, protocmp.Transform())
+	assert.NilError(t, tx.Transmit(ctx, "camera.front", []byte("image")))
+	// then the node should dispatch the messages and stop cleanly when canceled
+	assert.NilError(t, g.Wait())
+	assert.DeepEqual(t, []string{"camera.front"}, channels)
+	assert.ErrorContains(t, node.Subscribe(ctx, "foo", nil), "node is running")
+}
+
+func TestLCM_Node_SubscriberError(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
+	assert.NilError(t, err)
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	node := NewNode(rx, tx)
+	defer func() {
+		assert.NilError(t, node.Close())
+	}()
+	// given a subscriber that fails
+	errHandler := errors.New("boom")
+	assert.NilError(t, node.Subscribe(ctx, "foo", func(context.Context, *Message) error {
+		return errHandler
+	}))
+	var g errgroup.Group
+	g.Go(func() error {
+		return node.Run(ctx)
+	})
+	// when a message is received
+	assert.NilError(t, tx.Transmit(ctx, "foo", []byte("bar")))
+	// then the node should stop with the error of the subscriber
+	assert.ErrorIs(t, g.Wait(), errHandler)
+}
+
+func TestLCM_Node_SubscriberErrorThenCancel(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
+	assert.NilError(t, err)
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	node := NewNode(rx, tx)
+	defer func() {
+		assert.NilError(t, node.Close())
+	}()
+	// given a subscriber that fails, and a subscriber that cancels the context of the node once stopping
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	errHandler := errors.New("boom")
+	waiting := make(chan struct{})
+	assert.NilError(t, node.Subscribe(ctx, "fail", func(ctx context.Context, _ *Message) error {
+		select {
+		case <-waiting:
+		case <-ctx.Done():
+		}
+		return errHandler
+	}))
+	assert.NilError(t, node.Subscribe(ctx, "cancel", func(ctx context.Context, _ *Message) error {
+		close(waiting)
+		<-ctx.Done()
+		cancelRun()
+		return nil
+	}))
+	var g errgroup.Group
+	g.Go(func() error {
+		return node.Run(runCtx)
+	})
+	// when the subscriber fails, and the context is canceled before the node stops
+	assert.NilError(t, tx.Transmit(ctx, "cancel", []byte("bar")))
+	assert.NilError(t, tx.Transmit(ctx, "fail", []byte("bar")))
+	// then the node should stop with the error of the subscriber
+	assert.ErrorIs(t, g.Wait(), errHandler)
+}
+
+func TestLCM_Node_MalformedProto(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	metrics := &recordingMetrics{}
+	rx, err := ListenUDP(
+		ctx,
+		WithReceivePort(freePort),
+		WithReceiveSkipMalformed(true),
+		WithReceiveMetrics(metrics),
+	)
+	assert.NilError(t, err)
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	node := NewNode(rx, tx)
+	defer func() {
+		assert.NilError(t, node.Close())
+	}()
+	// given a typed subscriber on a receiver skipping malformed messages
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	received := make(chan *timestamppb.Timestamp, 1)
+	assert.NilError(t, SubscribeProto(ctx, node, "", func(_ context.Context, m *timestamppb.Timestamp) error {
+		received <- m
+		stop()
+		return nil
+	}))
+	var g errgroup.Group
+	g.Go(func() error {
+		return node.Run(runCtx)
+	})
+	// when a malformed and a valid proto message are received
+	assert.NilError(t, tx.Transmit(ctx, "google.protobuf.Timestamp", []byte{0xff}))
+	expected := &timestamppb.Timestamp{Seconds: 1}
+	assert.NilError(t, tx.TransmitProto(ctx, expected))
+	// then the malformed message should be counted and skipped
+	assert.DeepEqual(t, expected, <-received, protocmp.Transform())
+	assert.NilError(t, g.Wait())
+	assert.Assert(t, slices.Contains(metrics.events(), "DecodeFailed google.protobuf.Timestamp"))
+	assert.Equal(t, uint64(1), rx.Stats().Malformed)
+}
+
+func TestLCM_Node_Close(t *testing.T) {
+	// setup
+	const testTimeout = 1 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	freePort := getFreePort(t)
+	rx, err := ListenUDP(ctx, WithReceivePort(freePort))
+	assert.NilError(t, err)
+	tx, err := DialUDP(ctx, WithTransmitAddress(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: freePort}))
+	assert.NilError(t, err)
+	node := NewNode(rx, tx)
+	// given a running node without subscribers
+	var g errgroup.Group
+	g.Go(func() error {
+		return node.Run(ctx)
+	})
+	// when the node is closed, twice
+	assert.NilError(t, node.Close())
+	assert.NilError(t, node.Close())
+	// then the node should stop with an error
+	assert.ErrorContains(t, g.Wait(), "run node")
+	assert.NilError(t, node.Close())
 }
@@ -0,0 +1,237 @@
+package lcmbpf
+
+import (
+	"fmt"
+
+	"golang.org/x/net/bpf"
+)
+
+// MaxInstructions is the maximum number of instructions in a classic BPF program accepted by the Linux kernel.
+//
+// Socket filters are also limited in size by the net.core.optmem_max sysctl, which may reject much shorter programs.
+const MaxInstructions = 4096
+
+// maxConditionalSkip is the maximum number of instructions a conditional jump can skip.
+const maxConditionalSkip = 255
+
+// label is a symbolic jump target in a program under assembly.
+type label int
+
+// opKind is the kind of an assembler operation.
+type opKind int
+
+const (
+	// opInstruction is a non-jump instruction.
+	opInstruction opKind = iota
+	// opJumpIf is a conditional jump to symbolic targets.
+	opJumpIf
+	// opJump is an unconditional jump to a symbolic target.
+	opJump
+	// opLabel is the definition of a label, pointing to the next instruction.
+	opLabel
+)
+
+// op is an assembler operation.
+type op struct {
+	kind        opKind
+	instruction bpf.Instruction
+	cond        bpf.JumpTest
+	val         uint32
+	// compareX is set when a conditional jump compares the A register with the X register.
+	compareX  bool
+	jumpTrue  label
+	jumpFalse label
+	// expandTrue and expandFalse are set when a conditional jump target is too far away for a conditional jump, and
+	// an unconditional jump trampoline needs to be inserted.
+	expandTrue  bool
+	expandFalse bool
+}
+
+// size returns the number of instructions emitted for the operation.
+func (o *op) size() int {
+	switch o.kind {
+	case opLabel:
+		return 0
+	case opJumpIf:
+		n := 1
+		if o.expandTrue {
+			n++
+		}
+		if o.expandFalse {
+			n++
+		}
+		return n
+	default:
+		return 1
+	}
+}
+
+// assembler assembles BPF programs with symbolic jump targets.
+type assembler struct {
+	ops    []op
+	labels int
+}
+
+// newLabel returns a new label, which must be placed before assembly.
+func (a *assembler) newLabel() label {
+	a.labels++
+	return label(a.labels)
+}
+
+// place the label at the current position in the program.
+func (a *assembler) place(l label) {
+	a.ops = append(a.ops, op{kind: opLabel, jumpTrue: l})
+}
+
+// emit non-jump instructions.
+func (a *assembler) emit(instructions ...bpf.Instruction) {
+	for _, instruction := range instructions {
+		a.ops = append(a.ops, op{kind: opInstruction, instruction: instruction})
+	}
+}
+
+// jumpIf emits a conditional jump comparing the A register with a constant.
+func (a *assembler) jumpIf(cond bpf.JumpTest, val uint32, jumpTrue, jumpFalse label) {
+	a.ops = append(a.ops, op{kind: opJumpIf, cond: cond, val: val, jumpTrue: jumpTrue, jumpFalse: jumpFalse})
+}
+
+// jumpIfX emits a conditional jump comparing the A register with the X register.
+func (a *assembler) jumpIfX(cond bpf.JumpTest, jumpTrue, jumpFalse label) {
+	a.ops = append(a.ops, op{kind: opJumpIf, cond: cond, compareX: true, jumpTrue: jumpTrue, jumpFalse: jumpFalse})
+}
+
+// jumpUnless emits a conditional jump to onFalse, unless the A register compares true with a constant.
+func (a *assembler) jumpUnless(cond bpf.JumpTest, val uint32, onFalse label) {
+	next := a.newLabel()
+	a.jumpIf(cond, val, next, onFalse)
+	a.place(next)
+}
+
+// jump emits an unconditional jump.
+func (a *assembler) jump(target label) {
+	a.ops = append(a.ops, op{kind: opJump, jumpTrue: target})
+}
+
+// layout returns the instruction index of each operation and each label.
+func (a *assembler) layout() ([]int, map[label]int) {
+	positions := make([]int, len(a.ops))
+	labels := make(map[label]int, a.labels)
+	var pos int
+	for i := range a.ops {
+		positions[i] = pos
+		if a.ops[i].kind == opLabel {
+			labels[a.ops[i].jumpTrue] = pos
+		}
+		pos += a.ops[i].size()
+	}
+	return positions, labels
+}
+
+// removeRedundantJumps removes unconditional jumps to the immediately following instruction.
+func (a *assembler) removeRedundantJumps() {
+	ops := a.ops[:0]
+	for i, o := range a.ops {
+		if o.kind == opJump && a.isNext(i, o.jumpTrue) {
+			continue
+		}
+		ops = append(ops, o)
+	}
+	a.ops = ops
+}
+
+// isNext reports if the label is placed before the next instruction after the operation at index i.
+func (a *assembler) isNext(i int, l label) bool {
+	for _, o := range a.ops[i+1:] {
+		if o.kind != opLabel {
+			return false
+		}
+		if o.jumpTrue == l {
+			return true
+		}
+	}
+	return false
+}
+
+// assemble the program, resolving symbolic jump targets.
+func (a *assembler) assemble() ([]bpf.Instruction, error) {
+	a.removeRedundantJumps()
+	var positions []int
+	var labels map[label]int
+	// expand conditional jumps to far targets until all jumps are in range
+	for expanded := true; expanded; {
+		expanded = false
+		positions, labels = a.layout()
+		for i := range a.ops {
+			o := &a.ops[i]
+			if o.kind != opJumpIf {
+				continue
+			}
+			for _, target := range []struct {
+				l        label
+				expanded *bool
+			}{
+				{l: o.jumpTrue, expanded: &o.expandTrue},
+				{l: o.jumpFalse, expanded: &o.expandFalse},
+			} {
+				pos, ok := labels[target.l]
+				if !ok {
+					return nil, fmt.Errorf("assemble BPF: label %d not placed", target.l)
+				}
+				if skip := pos - positions[i] - 1; !*target.expanded && skip > maxConditionalSkip {
+					*target.expanded = true
+					expanded = true
+				}
+			}
+		}
+	}
+	program := make([]bpf.Instruction, 0, len(a.ops))
+	for i, o := range a.ops {
+		pos := positions[i]
+		switch o.kind {
+		case opLabel:
+		case opInstruction:
+			program = append(program, o.instruction)
+		case opJump:
+			skip := labels[o.jumpTrue] - pos - 1
+			if skip < 0 {
+				return nil, fmt.Errorf("assemble BPF: backward jump at instruction %d", pos)
+			}
+			program = append(program, bpf.Jump{Skip: uint32(skip)})
+		case opJumpIf:
+			var skipTrue, skipFalse uint8
+			var trampolines []bpf.Instruction
+			trampolinePos := pos + 1
+			for _, target := range []struct {
+				l        label
+				expanded bool
+				skip     *uint8
+			}{
+				{l: o.jumpTrue, expanded: o.expandTrue, skip: &skipTrue},
+				{l: o.jumpFalse, expanded: o.expandFalse, skip: &skipFalse},
+			} {
+				if target.expanded {
+					*target.skip = uint8(trampolinePos - pos - 1)
+					skip := labels[target.l] - trampolinePos - 1
+					if skip < 0 {
+						return nil, fmt.Errorf("assemble BPF: backward jump at instruction %d", pos)
+					}
+					trampolines = append(trampolines, bpf.Jump{Skip: uint32(skip)})
+					trampolinePos++
+					continue
+				}
+				skip := labels[target.l] - pos - 1
+				if skip < 0 {
+					return nil, fmt.Errorf("assemble BPF: backward jump at instruction %d", pos)
+				}
+				*target.skip = uint8(skip)
+			}
+			if o.compareX {
+				program = append(program, bpf.JumpIfX{Cond: o.cond, SkipTrue: skipTrue, SkipFalse: skipFalse})
+			} else {
+				program = append(program, bpf.JumpIf{Cond: o.cond, Val: o.val, SkipTrue: skipTrue, SkipFalse: skipFalse})
+			}
+			program = append(program, trampolines...)
+		}
+	}
+	return program, nil
+}
@@ -0,0 +1,5 @@
+// Package lcmbpf provides composable Berkeley Packet Filter (BPF) programs for LCM receiver sockets.
+//
+// Conditions on LCM datagrams, such as channel names and source addresses, are combined with All, Any and Not, and
+// assembled into a single classic BPF socket filter program with Assemble.
+package lcmbpf
@@ -0,0 +1,437 @@
+package lcmbpf
+
+import (
+	"encoding/binary"
+	"net"
+	"slices"
+
+	"golang.org/x/net/bpf"
+)
+
+// datagram layout, as seen by a socket filter on a UDP socket.
+const (
+	// offsetSourcePort is the offset of the source port in the UDP header.
+	offsetSourcePort = 0
+	// lengthOfUDPHeader is the length in bytes of the UDP header preceding the LCM datagram.
+	lengthOfUDPHeader = 8
+	// offsetHeaderMagic is the offset of the LCM header magic.
+	offsetHeaderMagic = lengthOfUDPHeader
+	// offsetChannel is the offset of the channel in an LCM short message.
+	offsetChannel = lengthOfUDPHeader + 8
+	// offsetNetworkHeader is the Linux offset (SKF_NET_OFF) for loads relative to the network header.
+	offsetNetworkHeader = 0xfff00000
+	// offsetIPv4SourceAddress is the offset of the source address in the IPv4 header.
+	offsetIPv4SourceAddress = offsetNetworkHeader + 12
+	// offsetIPv4DestinationAddress is the offset of the destination address in the IPv4 header.
+	offsetIPv4DestinationAddress = offsetNetworkHeader + 16
+)
+
+// LCM constants.
+const (
+	// shortMessageMagic is the uint32 magic number signifying a short LCM message.
+	shortMessageMagic = 0x4c433032
+	// fragmentMessageMagic is the uint32 magic number signifying a fragmented LCM message.
+	fragmentMessageMagic = 0x4c433033
+	// lengthOfLongestChannel is the length in bytes of the longest LCM channel, excluding the null byte.
+	lengthOfLongestChannel = 63
+	// acceptLength is the number of bytes of an accepted datagram to keep.
+	acceptLength = 0xffff
+)
+
+// Condition is a condition on a received LCM datagram.
+//
+// Conditions that load data beyond the end of a datagram cause the whole program to reject the datagram.
+type Condition interface {
+	// compile the condition, jumping to onTrue if the condition holds and to onFalse otherwise.
+	compile(a *assembler, onTrue, onFalse label)
+}
+
+// conditionFunc is a function implementing Condition.
+type conditionFunc func(a *assembler, onTrue, onFalse label)
+
+func (f conditionFunc) compile(a *assembler, onTrue, onFalse label) {
+	f(a, onTrue, onFalse)
+}
+
+// Assemble a BPF socket filter program accepting the datagrams matching the condition.
+//
+// Programs longer than MaxInstructions are rejected by the kernel.
+func Assemble(condition Condition) ([]bpf.Instruction, error) {
+	var a assembler
+	accept, reject := a.newLabel(), a.newLabel()
+	condition.compile(&a, accept, reject)
+	a.place(reject)
+	a.emit(bpf.RetConstant{Val: 0})
+	a.place(accept)
+	a.emit(bpf.RetConstant{Val: acceptLength})
+	return a.assemble()
+}
+
+// All returns a condition that holds when all the conditions hold.
+//
+// All without conditions always holds.
+func All(conditions ...Condition) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		if len(conditions) == 0 {
+			a.jump(onTrue)
+			return
+		}
+		for _, condition := range conditions[:len(conditions)-1] {
+			next := a.newLabel()
+			condition.compile(a, next, onFalse)
+			a.place(next)
+		}
+		conditions[len(conditions)-1].compile(a, onTrue, onFalse)
+	})
+}
+
+// Any returns a condition that holds when any of the conditions hold.
+//
+// Any without conditions never holds.
+func Any(conditions ...Condition) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		if len(conditions) == 0 {
+			a.jump(onFalse)
+			return
+		}
+		for _, condition := range conditions[:len(conditions)-1] {
+			next := a.newLabel()
+			condition.compile(a, onTrue, next)
+			a.place(next)
+		}
+		conditions[len(conditions)-1].compile(a, onTrue, onFalse)
+	})
+}
+
+// Not returns a condition that holds when the condition does not hold.
+func Not(condition Condition) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		condition.compile(a, onFalse, onTrue)
+	})
+}
+
+// ShortMessage returns a condition that holds for LCM short messages.
+func ShortMessage() Condition {
+	return headerMagic(shortMessageMagic)
+}
+
+// FragmentMessage returns a condition that holds for fragments of LCM fragmented messages.
+func FragmentMessage() Condition {
+	return headerMagic(fragmentMessageMagic)
+}
+
+// headerMagic returns a condition that holds for LCM datagrams with the header magic.
+func headerMagic(magic uint32) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		a.emit(bpf.LoadAbsolute{Off: offsetHeaderMagic, Size: 4})
+		a.jumpIf(bpf.JumpEqual, magic, onTrue, onFalse)
+	})
+}
+
+// Channel returns a condition that holds for LCM short messages where the channel equals any of the channels.
+//
+// Channel params, such as compression, are ignored when comparing channels.
+//
+// The channels are grouped by their first 4 bytes, which are compared in a binary search tree, and the channels within
+// a group are compared one by one. The program size grows linearly with the total length of the channels, and is about
+// 2 instructions per 4 bytes of channel.
+func Channel(channels ...string) Condition {
+	return All(ShortMessage(), conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		// group channels by their first word, channels shorter than a word are compared linearly
+		groups := make(map[uint32][]string)
+		var words []uint32
+		var shortChannels []string
+		for _, channel := range channels {
+			if len(channel) < 4 {
+				shortChannels = append(shortChannels, channel)
+				continue
+			}
+			word := binary.BigEndian.Uint32([]byte(channel))
+			if _, ok := groups[word]; !ok {
+				words = append(words, word)
+			}
+			groups[word] = append(groups[word], channel)
+		}
+		slices.Sort(words)
+		compareShortChannels := onFalse
+		if len(shortChannels) > 0 {
+			compareShortChannels = a.newLabel()
+		}
+		if len(words) > 0 {
+			groupLabels := make(map[uint32]label, len(words))
+			for _, word := range words {
+				groupLabels[word] = a.newLabel()
+			}
+			a.emit(bpf.LoadAbsolute{Off: offsetChannel, Size: 4})
+			compileWordTree(a, words, groupLabels, compareShortChannels)
+			for _, word := range words {
+				a.place(groupLabels[word])
+				compileChannelsEqual(a, groups[word], 4, onTrue, compareShortChannels)
+			}
+		}
+		if len(shortChannels) > 0 {
+			a.place(compareShortChannels)
+			compileChannelsEqual(a, shortChannels, 0, onTrue, onFalse)
+		}
+		if len(channels) == 0 {
+			a.jump(onFalse)
+		}
+	}))
+}
+
+// compileWordTree compiles a binary search for the A register in the sorted words, jumping to the label of the
+// matching word or to onFalse when no word matches.
+func compileWordTree(a *assembler, words []uint32, labels map[uint32]label, onFalse label) {
+	const maxLinearWords = 3
+	if len(words) <= maxLinearWords {
+		for _, word := range words[:len(words)-1] {
+			nextWord := a.newLabel()
+			a.jumpIf(bpf.JumpEqual, word, labels[word], nextWord)
+			a.place(nextWord)
+		}
+		a.jumpIf(bpf.JumpEqual, words[len(words)-1], labels[words[len(words)-1]], onFalse)
+		return
+	}
+	middle := len(words) / 2
+	lower, upper := a.newLabel(), a.newLabel()
+	a.jumpIf(bpf.JumpGreaterOrEqual, words[middle], upper, lower)
+	a.place(lower)
+	compileWordTree(a, words[:middle], labels, onFalse)
+	a.place(upper)
+	compileWordTree(a, words[middle:], labels, onFalse)
+}
+
+// compileChannelsEqual compiles a linear comparison of the channel of an LCM short message with the channels,
+// starting from the provided byte offset in the channels.
+func compileChannelsEqual(a *assembler, channels []string, from int, onTrue, onFalse label) {
+	for _, channel := range channels[:len(channels)-1] {
+		nextChannel := a.newLabel()
+		compileChannelEqual(a, channel, from, onTrue, nextChannel)
+		a.place(nextChannel)
+	}
+	compileChannelEqual(a, channels[len(channels)-1], from, onTrue, onFalse)
+}
+
+// compileChannelEqual compiles a comparison of the channel of an LCM short message with the channel, starting from
+// the provided byte offset in the channel.
+//
+// The channel in the message is terminated by a null byte, or by a '?' when the channel has params.
+func compileChannelEqual(a *assembler, channel string, from int, onTrue, onFalse label) {
+	if len(channel) > lengthOfLongestChannel {
+		a.jump(onFalse)
+		return
+	}
+	remaining := []byte(channel)[from:]
+	i := uint32(from)
+	for ; len(remaining) >= 4; i += 4 {
+		a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: 4})
+		a.jumpUnless(bpf.JumpEqual, binary.BigEndian.Uint32(remaining), onFalse)
+		remaining = remaining[4:]
+	}
+	var val uint32
+	var size int
+	switch len(remaining) {
+	case 0:
+		size = 1
+	case 1:
+		val, size = uint32(remaining[0])<<8, 2
+	case 2:
+		val, size = uint32(remaining[1])<<8|uint32(remaining[0])<<16, 4
+	case 3:
+		val, size = uint32(remaining[2])<<8|uint32(remaining[1])<<16|uint32(remaining[0])<<24, 4
+	}
+	a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: size})
+	if len(remaining) == 2 {
+		// When this happens we actually read 1 byte into the payload. So a packet with no payload
+		// will be rejected too. But why would you do that?
+		a.emit(bpf.ALUOpConstant{Op: bpf.ALUOpShiftRight, Val: 0x8})
+	}
+	// Accept on channel match, or if there is a query parameter.
+	matchParams := a.newLabel()
+	a.jumpIf(bpf.JumpEqual, val, onTrue, matchParams)
+	a.place(matchParams)
+	a.jumpIf(bpf.JumpEqual, val|'?', onTrue, onFalse)
+}
+
+// ChannelPrefix returns a condition that holds for LCM short messages where the channel starts with any of the
+// prefixes.
+func ChannelPrefix(prefixes ...string) Condition {
+	return All(ShortMessage(), conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		if len(prefixes) == 0 {
+			a.jump(onFalse)
+			return
+		}
+		for _, prefix := range prefixes[:len(prefixes)-1] {
+			nextPrefix := a.newLabel()
+			compileChannelPrefix(a, prefix, onTrue, nextPrefix)
+			a.place(nextPrefix)
+		}
+		compileChannelPrefix(a, prefixes[len(prefixes)-1], onTrue, onFalse)
+	}))
+}
+
+// compileChannelPrefix compiles a comparison of the start of the channel of an LCM short message with the prefix.
+func compileChannelPrefix(a *assembler, prefix string, onTrue, onFalse label) {
+	if len(prefix) > lengthOfLongestChannel {
+		a.jump(onFalse)
+		return
+	}
+	remaining := []byte(prefix)
+	var i uint32
+	for ; len(remaining) > 4; i += 4 {
+		a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: 4})
+		a.jumpUnless(bpf.JumpEqual, binary.BigEndian.Uint32(remaining), onFalse)
+		remaining = remaining[4:]
+	}
+	switch len(remaining) {
+	case 0:
+		a.jump(onTrue)
+	case 1:
+		a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: 1})
+		a.jumpIf(bpf.JumpEqual, uint32(remaining[0]), onTrue, onFalse)
+	case 2:
+		a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: 2})
+		a.jumpIf(bpf.JumpEqual, uint32(binary.BigEndian.Uint16(remaining)), onTrue, onFalse)
+	case 3:
+		a.emit(
+			bpf.LoadAbsolute{Off: offsetChannel + i, Size: 4},
+			bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xffffff00},
+		)
+		a.jumpIf(
+			bpf.JumpEqual,
+			uint32(remaining[0])<<24|uint32(remaining[1])<<16|uint32(remaining[2])<<8,
+			onTrue,
+			onFalse,
+		)
+	case 4:
+		a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: 4})
+		a.jumpIf(bpf.JumpEqual, binary.BigEndian.Uint32(remaining), onTrue, onFalse)
+	}
+}
+
+// HasParams returns a condition that holds for LCM short messages with channel params, such as compression.
+//
+// The condition scans the channel for the params separator, and compiles to about 3 instructions per byte of the
+// longest possible channel.
+func HasParams() Condition {
+	return All(ShortMessage(), conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		for i := uint32(0); i < lengthOfLongestChannel; i++ {
+			a.emit(bpf.LoadAbsolute{Off: offsetChannel + i, Size: 1})
+			notParams := a.newLabel()
+			a.jumpIf(bpf.JumpEqual, '?', onTrue, notParams)
+			a.place(notParams)
+			a.jumpUnless(bpf.JumpNotEqual, 0, onFalse)
+		}
+		a.jump(onFalse)
+	}))
+}
+
+// SourceIP returns a condition that holds for datagrams from any of the IPv4 source addresses.
+//
+// The condition loads from the network header, which is supported by Linux but not by the bpf package VM.
+func SourceIP(ips ...net.IP) Condition {
+	return ipv4Address(offsetIPv4SourceAddress, ips)
+}
+
+// DestinationIP returns a condition that holds for datagrams to any of the IPv4 destination addresses, such as
+// multicast groups.
+//
+// The condition loads from the network header, which is supported by Linux but not by the bpf package VM.
+func DestinationIP(ips ...net.IP) Condition {
+	return ipv4Address(offsetIPv4DestinationAddress, ips)
+}
+
+// ipv4Address returns a condition that holds for datagrams where the IPv4 address at the offset equals any of the
+// addresses.
+func ipv4Address(offset uint32, ips []net.IP) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		a.emit(bpf.LoadAbsolute{Off: offset, Size: 4})
+		for _, ip := range ips {
+			ip4 := ip.To4()
+			if ip4 == nil {
+				continue
+			}
+			next := a.newLabel()
+			a.jumpIf(bpf.JumpEqual, binary.BigEndian.Uint32(ip4), onTrue, next)
+			a.place(next)
+		}
+		a.jump(onFalse)
+	})
+}
+
+// SourcePort returns a condition that holds for datagrams from any of the UDP source ports.
+func SourcePort(ports ...int) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		a.emit(bpf.LoadAbsolute{Off: offsetSourcePort, Size: 2})
+		for _, port := range ports {
+			next := a.newLabel()
+			a.jumpIf(bpf.JumpEqual, uint32(port), onTrue, next)
+			a.place(next)
+		}
+		a.jump(onFalse)
+	})
+}
+
+// opRetX is the opcode of a BPF instruction returning the X register, which has no bpf.Instruction type.
+const opRetX = 0x06 | 0x08
+
+// Program returns a condition that holds when the BPF program accepts the datagram.
+//
+// Use Program to combine custom BPF programs with other conditions. The returns of the program, including raw return
+// instructions, are rewritten into jumps to the rest of the combined program.
+func Program(program []bpf.Instruction) Condition {
+	return conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		labels := make([]label, len(program)+1)
+		for i := range labels {
+			labels[i] = a.newLabel()
+		}
+		target := func(i int, skip uint32) label {
+			if j := i + 1 + int(skip); j < len(labels) {
+				return labels[j]
+			}
+			return onFalse
+		}
+		for i, instruction := range program {
+			a.place(labels[i])
+			if raw, ok := instruction.(bpf.RawInstruction); ok {
+				instruction = raw.Disassemble()
+			}
+			switch instruction := instruction.(type) {
+			case bpf.RetConstant:
+				if instruction.Val == 0 {
+					a.jump(onFalse)
+				} else {
+					a.jump(onTrue)
+				}
+			case bpf.RetA:
+				a.jumpIf(bpf.JumpEqual, 0, onFalse, onTrue)
+			case bpf.RawInstruction:
+				if instruction.Op != opRetX {
+					a.emit(instruction)
+					break
+				}
+				a.emit(bpf.TXA{})
+				a.jumpIf(bpf.JumpEqual, 0, onFalse, onTrue)
+			case bpf.Jump:
+				a.jump(target(i, instruction.Skip))
+			case bpf.JumpIf:
+				a.jumpIf(
+					instruction.Cond,
+					instruction.Val,
+					target(i, uint32(instruction.SkipTrue)),
+					target(i, uint32(instruction.SkipFalse)),
+				)
+			case bpf.JumpIfX:
+				a.jumpIfX(
+					instruction.Cond,
+					target(i, uint32(instruction.SkipTrue)),
+					target(i, uint32(instruction.SkipFalse)),
+				)
+			default:
+				a.emit(instruction)
+			}
+		}
+		a.place(labels[len(program)])
+		a.jump(onFalse)
+	})
+}
@@ -0,0 +1,242 @@
+package lcmbpf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"gotest.tools/v3/assert"
+)
+
+func TestAssemble(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		condition Condition
+		packet    []byte
+		expected  int
+	}{
+		{
+			name:      "channel accepted 1",
+			condition: Channel("foo", "barbaz"),
+			packet:    shortMessage(1, "foo"),
+			expected:  0xffff,
+		},
+		{
+			name:      "channel accepted query parameters",
+			condition: Channel("foo", "barbaz"),
+			packet:    shortMessage(1, "barbaz?m=1"),
+			expected:  0xffff,
+		},
+		{
+			name:      "channel accepted 2",
+			condition: Channel("foo", "barbaz"),
+			packet:    shortMessage(1, "barbaz", 0),
+			expected:  0xffff,
+		},
+		{
+			name:      "channel accepted 3",
+			condition: Channel("foo", "tutan"),
+			packet:    shortMessage(1, "tutan"),
+			expected:  0xffff,
+		},
+		{
+			name:      "channel rejected due to wrong channel",
+			condition: Channel("foo", "barbaz"),
+			packet:    shortMessage(1, "bar"),
+			expected:  0,
+		},
+		{
+			name:      "channel rejected due to wrong header magic",
+			condition: Channel("foo", "barbaz"),
+			packet: []byte{
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
+				0x4c, 0x43, 0x30, 0x00, // wrong magic
+				0x00, 0x00, 0x00, 0x01, // sequence number
+				'f', 'o', 'o', 0, // channel
+			},
+			expected: 0,
+		},
+		{
+			name:      "channel prefix accepted",
+			condition: ChannelPrefix("vehicle.sensors."),
+			packet:    shortMessage(1, "vehicle.sensors.lidar", 1, 2, 3),
+			expected:  0xffff,
+		},
+		{
+			name:      "channel prefix accepted equal",
+			condition: ChannelPrefix("foo.bar"),
+			packet:    shortMessage(1, "foo.bar", 1),
+			expected:  0xffff,
+		},
+		{
+			name:      "channel prefix rejected",
+			condition: ChannelPrefix("vehicle.sensors."),
+			packet:    shortMessage(1, "vehicle.state", 1, 2, 3),
+			expected:  0,
+		},
+		{
+			name:      "has params accepted",
+			condition: HasParams(),
+			packet:    shortMessage(1, "foo?z=lz4", 1, 2, 3),
+			expected:  0xffff,
+		},
+		{
+			name:      "has params rejected",
+			condition: HasParams(),
+			packet:    shortMessage(1, "foo", '?', 2, 3),
+			expected:  0,
+		},
+		{
+			name:      "source port accepted",
+			condition: SourcePort(1234, 5678),
+			packet:    append([]byte{0x16, 0x2e}, shortMessage(1, "foo")[2:]...),
+			expected:  0xffff,
+		},
+		{
+			name:      "source port rejected",
+			condition: SourcePort(1234),
+			packet:    shortMessage(1, "foo"),
+			expected:  0,
+		},
+		{
+			name:      "fragment accepted",
+			condition: Any(FragmentMessage(), Channel("foo")),
+			packet: []byte{
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
+				0x4c, 0x43, 0x30, 0x33, // fragment magic
+				0x00, 0x00, 0x00, 0x01, // sequence number
+			},
+			expected: 0xffff,
+		},
+		{
+			name:      "not channel accepted",
+			condition: All(ShortMessage(), Not(Channel("foo"))),
+			packet:    shortMessage(1, "bar"),
+			expected:  0xffff,
+		},
+		{
+			name:      "not channel rejected",
+			condition: All(ShortMessage(), Not(Channel("foo"))),
+			packet:    shortMessage(1, "foo"),
+			expected:  0,
+		},
+		{
+			name: "program accepted",
+			condition: All(
+				Program([]bpf.Instruction{
+					bpf.LoadAbsolute{Off: 12, Size: 4},
+					bpf.JumpIf{Cond: bpf.JumpEqual, Val: 1, SkipFalse: 1},
+					bpf.RetConstant{Val: 1},
+					bpf.RetConstant{Val: 0},
+				}),
+				Channel("foo"),
+			),
+			packet:   shortMessage(1, "foo"),
+			expected: 0xffff,
+		},
+		{
+			name: "program rejected",
+			condition: All(
+				Program([]bpf.Instruction{
+					bpf.LoadAbsolute{Off: 12, Size: 4},
+					bpf.JumpIf{Cond: bpf.JumpEqual, Val: 1, SkipFalse: 1},
+					bpf.RetConstant{Val: 1},
+					bpf.RetConstant{Val: 0},
+				}),
+				Channel("foo"),
+			),
+			packet:   shortMessage(2, "foo"),
+			expected: 0,
+		},
+		{
+			name:      "not raw program",
+			condition: Any(Not(Program([]bpf.Instruction{bpf.RawInstruction{Op: 0x06, K: 1}})), Channel("foo")),
+			packet:    shortMessage(1, "foo"),
+			expected:  0xffff,
+		},
+		{
+			name: "not program returning X",
+			condition: All(
+				Not(Program([]bpf.Instruction{bpf.LoadConstant{Dst: bpf.RegX, Val: 0}, bpf.RawInstruction{Op: 0x0e}})),
+				Channel("foo"),
+			),
+			packet:   shortMessage(1, "bar"),
+			expected: 0,
+		},
+		{
+			name: "program returning X",
+			condition: Any(
+				Program([]bpf.Instruction{bpf.LoadConstant{Dst: bpf.RegX, Val: 0}, bpf.RawInstruction{Op: 0x0e}}),
+				Channel("foo"),
+			),
+			packet:   shortMessage(1, "foo"),
+			expected: 0xffff,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := Assemble(tt.condition)
+			assert.NilError(t, err)
+			vm, err := bpf.NewVM(program)
+			assert.NilError(t, err)
+			n, err := vm.Run(tt.packet)
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, n)
+		})
+	}
+}
+
+func TestAssemble_LongJumps(t *testing.T) {
+	channels := make([]string, 0, 50)
+	for i := 0; i < cap(channels); i++ {
+		channels = append(channels, strings.Repeat(string(rune('a'+i%26)), 10+i%20)+string(rune('A'+i/26)))
+	}
+	program, err := Assemble(Any(FragmentMessage(), Channel(channels...)))
+	assert.NilError(t, err)
+	assert.Assert(t, len(program) > 255)
+	vm, err := bpf.NewVM(program)
+	assert.NilError(t, err)
+	for _, channel := range channels {
+		n, err := vm.Run(shortMessage(1, channel, make([]byte, 64)...))
+		assert.NilError(t, err)
+		assert.Equal(t, 0xffff, n, channel)
+	}
+	n, err := vm.Run(shortMessage(1, "foo", make([]byte, 64)...))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestChannel_Many(t *testing.T) {
+	const n = 200
+	channels := make([]string, 0, 2*n)
+	for i := 0; i < n; i++ {
+		channels = append(channels, fmt.Sprintf("vehicle.%d.state", i), fmt.Sprintf("c%d", i))
+	}
+	program, err := Assemble(Channel(channels...))
+	assert.NilError(t, err)
+	assert.Assert(t, len(program) <= MaxInstructions, len(program))
+	vm, err := bpf.NewVM(program)
+	assert.NilError(t, err)
+	for _, channel := range channels {
+		n, err := vm.Run(shortMessage(1, channel, make([]byte, 64)...))
+		assert.NilError(t, err)
+		assert.Equal(t, 0xffff, n, channel)
+	}
+	for _, channel := range []string{"vehicle.200.state", "vehicle.1.stat", "c200", "c", "foo"} {
+		n, err := vm.Run(shortMessage(1, channel, make([]byte, 64)...))
+		assert.NilError(t, err)
+		assert.Equal(t, 0, n, channel)
+	}
+}
+
+// shortMessage returns a UDP packet with an LCM short message.
+func shortMessage(sequenceNumber byte, channel string, payload ...byte) []byte {
+	packet := []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // UDP header
+		0x4c, 0x43, 0x30, 0x32, // magic
+		0x00, 0x00, 0x00, sequenceNumber, // sequence number
+	}
+	packet = append(packet, channel...)
+	packet = append(packet, 0)
+	return append(packet, payload...)
+}
@@ -0,0 +1,59 @@
+package lcmbpf
+
+import "strings"
+
+// wildcard is the channel pattern wildcard, matching any sequence of characters.
+const wildcard = '*'
+
+// ChannelPattern returns a condition that holds for LCM short messages where the channel may match any of the
+// patterns.
+//
+// Patterns are channel names where '*' matches any sequence of characters, for example "vehicle.sensors.*". Patterns
+// without wildcards compile to exact channel comparisons, and patterns ending with a single wildcard compile to
+// channel prefix comparisons. For other patterns, such as "vehicle.*.state", only the prefix before the first
+// wildcard is compared, and the channel must be matched against the pattern in userspace with MatchChannel.
+func ChannelPattern(patterns ...string) Condition {
+	var channels, prefixes []string
+	for _, pattern := range patterns {
+		if i := strings.IndexByte(pattern, wildcard); i >= 0 {
+			prefixes = append(prefixes, pattern[:i])
+		} else {
+			channels = append(channels, pattern)
+		}
+	}
+	switch {
+	case len(prefixes) == 0:
+		return Channel(channels...)
+	case len(channels) == 0:
+		return ChannelPrefix(prefixes...)
+	default:
+		return Any(Channel(channels...), ChannelPrefix(prefixes...))
+	}
+}
+
+// MatchChannel reports if the channel matches the pattern, where '*' matches any sequence of characters.
+func MatchChannel(pattern, channel string) bool {
+	// backtrack to the last wildcard on mismatch
+	var p, c, nextP, nextC int
+	for p < len(pattern) || c < len(channel) {
+		if p < len(pattern) {
+			switch {
+			case pattern[p] == wildcard:
+				// try matching the empty sequence first, and restart at the next channel character on mismatch
+				nextP, nextC = p, c+1
+				p++
+				continue
+			case c < len(channel) && pattern[p] == channel[c]:
+				p++
+				c++
+				continue
+			}
+		}
+		if nextC > 0 && nextC <= len(channel) {
+			p, c = nextP, nextC
+			continue
+		}
+		return false
+	}
+	return true
+}
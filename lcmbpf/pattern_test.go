@@ -0,0 +1,86 @@
+package lcmbpf
+
+import (
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"gotest.tools/v3/assert"
+)
+
+func TestMatchChannel(t *testing.T) {
+	for _, tt := range []struct {
+		pattern  string
+		channel  string
+		expected bool
+	}{
+		{pattern: "foo", channel: "foo", expected: true},
+		{pattern: "foo", channel: "foobar", expected: false},
+		{pattern: "foo*", channel: "foo", expected: true},
+		{pattern: "foo*", channel: "foobar", expected: true},
+		{pattern: "foo*", channel: "fo", expected: false},
+		{pattern: "*", channel: "", expected: true},
+		{pattern: "*", channel: "foo", expected: true},
+		{pattern: "vehicle.*.state", channel: "vehicle.front.state", expected: true},
+		{pattern: "vehicle.*.state", channel: "vehicle.front.status", expected: false},
+		{pattern: "vehicle.*.state", channel: "vehicle.state", expected: false},
+		{pattern: "*.state", channel: "a.state.b.state", expected: true},
+		{pattern: "a*b*c", channel: "abbbc", expected: true},
+		{pattern: "a*b*c", channel: "acb", expected: false},
+		{pattern: "", channel: "", expected: true},
+		{pattern: "", channel: "a", expected: false},
+	} {
+		t.Run(tt.pattern+" "+tt.channel, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MatchChannel(tt.pattern, tt.channel))
+		})
+	}
+}
+
+func TestChannelPattern(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		patterns []string
+		channel  string
+		expected int
+	}{
+		{
+			name:     "exact accepted",
+			patterns: []string{"foo", "vehicle.sensors.*"},
+			channel:  "foo",
+			expected: 0xffff,
+		},
+		{
+			name:     "exact rejected",
+			patterns: []string{"foo", "vehicle.sensors.*"},
+			channel:  "foobar",
+			expected: 0,
+		},
+		{
+			name:     "prefix accepted",
+			patterns: []string{"foo", "vehicle.sensors.*"},
+			channel:  "vehicle.sensors.lidar",
+			expected: 0xffff,
+		},
+		{
+			name:     "prefix rejected",
+			patterns: []string{"foo", "vehicle.sensors.*"},
+			channel:  "vehicle.state",
+			expected: 0,
+		},
+		{
+			name:     "prefix and suffix accepted by prefix",
+			patterns: []string{"vehicle.*.state"},
+			channel:  "vehicle.front.status",
+			expected: 0xffff,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := Assemble(ChannelPattern(tt.patterns...))
+			assert.NilError(t, err)
+			vm, err := bpf.NewVM(program)
+			assert.NilError(t, err)
+			n, err := vm.Run(shortMessage(1, tt.channel, make([]byte, 64)...))
+			assert.NilError(t, err)
+			assert.Equal(t, tt.expected, n)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package lcmbpf
+
+import (
+	"hash/fnv"
+
+	"golang.org/x/net/bpf"
+)
+
+// datagram layout, as seen by a reuseport program on a UDP socket, where the UDP header has been pulled.
+const (
+	// reusePortOffsetHeaderMagic is the offset of the LCM header magic.
+	reusePortOffsetHeaderMagic = 0
+	// reusePortOffsetChannel is the offset of the channel in an LCM short message.
+	reusePortOffsetChannel = 8
+)
+
+// FNV-1a constants.
+const (
+	fnvOffsetBasis = 2166136261
+	fnvPrime       = 16777619
+)
+
+// ChannelHash returns the hash of the channel computed by ChannelShard and ReusePortChannelShard.
+//
+// The hash is the 32-bit FNV-1a hash of the channel, excluding channel params.
+func ChannelHash(channel string) uint32 {
+	if len(channel) > lengthOfLongestChannel {
+		channel = channel[:lengthOfLongestChannel]
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(channel))
+	return h.Sum32()
+}
+
+// ChannelShard returns a condition that holds for LCM short messages where the channel hash modulo the number of
+// shards equals the shard.
+//
+// The condition compiles to about 8 instructions per byte of the longest possible channel.
+func ChannelShard(shards, shard int) Condition {
+	return All(ShortMessage(), conditionFunc(func(a *assembler, onTrue, onFalse label) {
+		compileChannelHash(a, offsetChannel)
+		a.emit(bpf.ALUOpConstant{Op: bpf.ALUOpMod, Val: uint32(shards)})
+		a.jumpIf(bpf.JumpEqual, uint32(shard), onTrue, onFalse)
+	}))
+}
+
+// ReusePortChannelShard assembles a BPF program for selecting a socket in an SO_REUSEPORT group by the channel hash
+// modulo the number of shards, to be attached with SO_ATTACH_REUSEPORT_CBPF.
+//
+// All messages on a channel are steered to the same socket. Datagrams that are not LCM short messages are steered to
+// the first socket.
+func ReusePortChannelShard(shards int) ([]bpf.Instruction, error) {
+	var a assembler
+	hash, other := a.newLabel(), a.newLabel()
+	a.emit(bpf.LoadAbsolute{Off: reusePortOffsetHeaderMagic, Size: 4})
+	a.jumpIf(bpf.JumpEqual, shortMessageMagic, hash, other)
+	a.place(other)
+	a.emit(bpf.RetConstant{Val: 0})
+	a.place(hash)
+	compileChannelHash(&a, reusePortOffsetChannel)
+	a.emit(bpf.ALUOpConstant{Op: bpf.ALUOpMod, Val: uint32(shards)}, bpf.RetA{})
+	return a.assemble()
+}
+
+// compileChannelHash compiles the FNV-1a hash of the channel starting at the offset into the A register.
+//
+// The channel is terminated by a null byte, or by a '?' when the channel has params.
+func compileChannelHash(a *assembler, offset uint32) {
+	done := a.newLabel()
+	a.emit(bpf.LoadConstant{Dst: bpf.RegA, Val: fnvOffsetBasis}, bpf.StoreScratch{Src: bpf.RegA, N: 0})
+	for i := uint32(0); i < lengthOfLongestChannel; i++ {
+		a.emit(bpf.LoadAbsolute{Off: offset + i, Size: 1})
+		a.jumpUnless(bpf.JumpNotEqual, 0, done)
+		a.jumpUnless(bpf.JumpNotEqual, '?', done)
+		a.emit(
+			bpf.TAX{},
+			bpf.LoadScratch{Dst: bpf.RegA, N: 0},
+			bpf.ALUOpX{Op: bpf.ALUOpXor},
+			bpf.ALUOpConstant{Op: bpf.ALUOpMul, Val: fnvPrime},
+			bpf.StoreScratch{Src: bpf.RegA, N: 0},
+		)
+	}
+	a.place(done)
+	a.emit(bpf.LoadScratch{Dst: bpf.RegA, N: 0})
+}
@@ -0,0 +1,59 @@
+package lcmbpf
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/bpf"
+	"gotest.tools/v3/assert"
+)
+
+func TestChannelHash(t *testing.T) {
+	// FNV-1a test vectors
+	assert.Equal(t, uint32(0x811c9dc5), ChannelHash(""))
+	assert.Equal(t, uint32(0xe40c292c), ChannelHash("a"))
+	assert.Equal(t, uint32(0xbf9cf968), ChannelHash("foobar"))
+}
+
+func TestChannelShard(t *testing.T) {
+	const shards = 4
+	vms := make([]*bpf.VM, 0, shards)
+	for shard := 0; shard < shards; shard++ {
+		program, err := Assemble(ChannelShard(shards, shard))
+		assert.NilError(t, err)
+		assert.Assert(t, len(program) <= MaxInstructions)
+		vm, err := bpf.NewVM(program)
+		assert.NilError(t, err)
+		vms = append(vms, vm)
+	}
+	for i := 0; i < 100; i++ {
+		channel := fmt.Sprintf("channel.%d", i)
+		expected := int(ChannelHash(channel) % shards)
+		for shard, vm := range vms {
+			n, err := vm.Run(shortMessage(1, channel, make([]byte, 64)...))
+			assert.NilError(t, err)
+			assert.Equal(t, shard == expected, n > 0, "channel %s shard %d", channel, shard)
+			n, err = vm.Run(shortMessage(1, channel+"?z=lz4", make([]byte, 64)...))
+			assert.NilError(t, err)
+			assert.Equal(t, shard == expected, n > 0, "channel %s?z=lz4 shard %d", channel, shard)
+		}
+	}
+}
+
+func TestReusePortChannelShard(t *testing.T) {
+	const shards = 3
+	program, err := ReusePortChannelShard(shards)
+	assert.NilError(t, err)
+	vm, err := bpf.NewVM(program)
+	assert.NilError(t, err)
+	for i := 0; i < 100; i++ {
+		channel := fmt.Sprintf("channel.%d", i)
+		// the UDP header has been pulled for reuseport programs
+		n, err := vm.Run(shortMessage(1, channel, make([]byte, 64)...)[8:])
+		assert.NilError(t, err)
+		assert.Equal(t, int(ChannelHash(channel)%shards), n, "channel %s", channel)
+	}
+	n, err := vm.Run([]byte{0x4c, 0x43, 0x30, 0x33, 0x00, 0x00, 0x00, 0x00})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -1,6 +1,7 @@
 package lcmlog
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -95,6 +96,13 @@ type Message struct {
 	Data        []byte
 }
 
+// Clone returns a deep copy of the message, with data owned by the caller.
+func (m *Message) Clone() *Message {
+	clone := *m
+	clone.Data = bytes.Clone(m.Data)
+	return &clone
+}
+
 func (m *Message) WriteTo(w io.Writer) (int64, error) {
 	n, err := w.Write(m.MarshalBinary())
 	if err != nil {
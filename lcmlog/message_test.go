@@ -21,3 +21,11 @@ func TestMessage_Marshaling(t *testing.T) {
 
 	assert.DeepEqual(t, originalMsg, actualMsg)
 }
+
+func TestMessage_Clone(t *testing.T) {
+	original := Message{EventNumber: 1, Timestamp: time.Unix(300, 0), Channel: "test", Data: []byte("test_data")}
+	clone := original.Clone()
+	assert.DeepEqual(t, &original, clone)
+	original.Data[0] = 'b'
+	assert.DeepEqual(t, []byte("test_data"), clone.Data)
+}
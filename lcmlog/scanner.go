@@ -47,6 +47,9 @@ func (s *Scanner) RawMessage() []byte {
 	return s.sc.Bytes()
 }
 
+// Message returns the last scanned message.
+//
+// The message data is only valid until the next call to Scan, use Message.Clone to keep it.
 func (s *Scanner) Message() *Message {
 	return &s.msg
 }
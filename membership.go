@@ -0,0 +1,235 @@
+package lcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// receiveInterfaces returns the interfaces to join the multicast groups on.
+//
+// A nil interface is returned for the default interface when no interfaces are configured.
+func (o *receiverOptions) receiveInterfaces() ([]*net.Interface, error) {
+	if o.allInterfaces {
+		ifis, err := net.Interfaces()
+		if err != nil {
+			return nil, fmt.Errorf("listing interfaces: %w", err)
+		}
+		var result []*net.Interface
+		for i := range ifis {
+			if ifis[i].Flags&net.FlagMulticast != 0 && ifis[i].Flags&net.FlagUp != 0 {
+				result = append(result, &ifis[i])
+			}
+		}
+		return result, nil
+	}
+	if len(o.interfaceNames) == 0 {
+		return []*net.Interface{nil}, nil
+	}
+	result := make([]*net.Interface, 0, len(o.interfaceNames))
+	for _, interfaceName := range o.interfaceNames {
+		ifi, err := multicastInterfaceByName(interfaceName)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ifi)
+	}
+	return result, nil
+}
+
+// multicastInterfaceByName returns the interface with the provided name, if it is an up multicast interface.
+func multicastInterfaceByName(interfaceName string) (*net.Interface, error) {
+	ifi, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s interface by name: %w", interfaceName, err)
+	}
+	if ifi.Flags&net.FlagMulticast == 0 {
+		return nil, fmt.Errorf("interface %s is not a multicast interface", ifi.Name)
+	}
+	if ifi.Flags&net.FlagUp == 0 {
+		return nil, fmt.Errorf("interface %s is not up", ifi.Name)
+	}
+	return ifi, nil
+}
+
+// joinInterfaces joins the multicast groups on the interfaces that have not already been joined.
+func (r *Receiver) joinInterfaces(ctx context.Context, ifis []*net.Interface) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ifi := range ifis {
+		index := interfaceIndex(ifi)
+		if _, ok := r.interfaces[index]; ok {
+			continue
+		}
+		if err := r.joinGroups(ctx, ifi); err != nil {
+			return err
+		}
+		r.interfaces[index] = ifi
+	}
+	return nil
+}
+
+// joinGroups joins the multicast groups on the interface, which may be nil for the default interface.
+//
+// When a group can not be joined, the groups already joined on the interface are left.
+func (r *Receiver) joinGroups(ctx context.Context, ifi *net.Interface) error {
+	for i, ip := range r.opts.ips {
+		// from: https://godoc.org/golang.org/x/net/ipv4#hdr-Multicasting
+		//
+		// Note that the service port for transport layer protocol does not matter with this operation as joining
+		// groups affects only network and link layer protocols, such as IPv4 and Ethernet.
+		if err := r.conn.JoinGroup(ifi, &net.UDPAddr{IP: ip}); err != nil {
+			return errors.Join(
+				fmt.Errorf("joining multicast group: IP %v: %w", ip, err),
+				r.leaveGroups(ctx, ifi, r.opts.ips[:i], nil),
+			)
+		}
+		r.opts.logger.LogAttrs(ctx, slog.LevelDebug, "joined multicast group", slog.Any("group", ip), interfaceAttr(ifi))
+	}
+	for i, ssm := range r.opts.sources {
+		if err := r.conn.JoinSourceSpecificGroup(ifi, &net.UDPAddr{IP: ssm.group}, &net.UDPAddr{IP: ssm.source}); err != nil {
+			return errors.Join(
+				fmt.Errorf("joining source-specific multicast group: IP %v: source %v: %w", ssm.group, ssm.source, err),
+				r.leaveGroups(ctx, ifi, r.opts.ips, r.opts.sources[:i]),
+			)
+		}
+		r.opts.logger.LogAttrs(
+			ctx,
+			slog.LevelDebug,
+			"joined source-specific multicast group",
+			slog.Any("group", ssm.group),
+			slog.Any("source", ssm.source),
+			interfaceAttr(ifi),
+		)
+	}
+	return nil
+}
+
+// leaveInterfaces leaves the multicast groups on all joined interfaces.
+func (r *Receiver) leaveInterfaces(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for index, ifi := range r.interfaces {
+		errs = append(errs, r.leaveGroups(ctx, ifi, r.opts.ips, r.opts.sources))
+		delete(r.interfaces, index)
+	}
+	return errors.Join(errs...)
+}
+
+// leaveGroups leaves the multicast groups and the source-specific multicast groups on the interface.
+func (r *Receiver) leaveGroups(
+	ctx context.Context, ifi *net.Interface, ips []net.IP, sources []sourceSpecificGroup,
+) error {
+	var errs []error
+	for _, ip := range ips {
+		if err := r.conn.LeaveGroup(ifi, &net.UDPAddr{IP: ip}); err != nil {
+			errs = append(errs, fmt.Errorf("leaving multicast group: IP %v: %w", ip, err))
+			continue
+		}
+		r.opts.logger.LogAttrs(ctx, slog.LevelDebug, "left multicast group", slog.Any("group", ip), interfaceAttr(ifi))
+	}
+	for _, ssm := range sources {
+		if err := r.conn.LeaveSourceSpecificGroup(
+			ifi, &net.UDPAddr{IP: ssm.group}, &net.UDPAddr{IP: ssm.source},
+		); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"leaving source-specific multicast group: IP %v: source %v: %w", ssm.group, ssm.source, err,
+			))
+			continue
+		}
+		r.opts.logger.LogAttrs(
+			ctx,
+			slog.LevelDebug,
+			"left source-specific multicast group",
+			slog.Any("group", ssm.group),
+			slog.Any("source", ssm.source),
+			interfaceAttr(ifi),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// refreshInterfaces periodically joins the multicast groups on interfaces that have appeared since the receiver was
+// created, until the receiver is closed.
+func (r *Receiver) refreshInterfaces(interval time.Duration) {
+	defer close(r.refreshDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	ctx := context.Background()
+	for {
+		select {
+		case <-r.closed:
+			return
+		case <-ticker.C:
+		}
+		if err := r.refreshInterfacesOnce(ctx); err != nil {
+			r.opts.logger.LogAttrs(ctx, slog.LevelWarn, "failed to refresh interfaces", slog.Any("error", err))
+		}
+	}
+}
+
+// refreshInterfacesOnce forgets joined interfaces that have been removed, and joins the multicast groups on new
+// interfaces.
+func (r *Receiver) refreshInterfacesOnce(ctx context.Context) error {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("listing interfaces: %w", err)
+	}
+	r.mu.Lock()
+	for index, ifi := range r.interfaces {
+		if ifi == nil {
+			continue
+		}
+		if !containsInterface(ifis, ifi) {
+			// memberships are dropped by the kernel when an interface is removed
+			r.opts.logger.LogAttrs(ctx, slog.LevelDebug, "interface removed", interfaceAttr(ifi))
+			delete(r.interfaces, index)
+		}
+	}
+	r.mu.Unlock()
+	if r.opts.allInterfaces {
+		selected, err := r.opts.receiveInterfaces()
+		if err != nil {
+			return err
+		}
+		return r.joinInterfaces(ctx, selected)
+	}
+	// skip named interfaces that are currently missing or down
+	var selected []*net.Interface
+	for _, interfaceName := range r.opts.interfaceNames {
+		if ifi, err := multicastInterfaceByName(interfaceName); err == nil {
+			selected = append(selected, ifi)
+		}
+	}
+	return r.joinInterfaces(ctx, selected)
+}
+
+// containsInterface reports if the interface, identified by its index and name, is in the list of interfaces.
+func containsInterface(ifis []net.Interface, ifi *net.Interface) bool {
+	for _, other := range ifis {
+		if other.Index == ifi.Index && other.Name == ifi.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceIndex returns the index of the interface, or 0 for the default interface.
+func interfaceIndex(ifi *net.Interface) int {
+	if ifi == nil {
+		return 0
+	}
+	return ifi.Index
+}
+
+// interfaceAttr returns a log attribute for the provided interface, which may be nil for the default interface.
+func interfaceAttr(ifi *net.Interface) slog.Attr {
+	if ifi == nil {
+		return slog.String("interface", "")
+	}
+	return slog.String("interface", ifi.Name)
+}
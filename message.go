@@ -3,9 +3,9 @@ package lcm
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"strings"
+	"sync"
 )
 
 // lengthOfLargestUDPMessage is length in bytes of the largest possible UDP message.
@@ -36,6 +36,14 @@ const (
 // shortMessageMagic is the uint32 magic number signifying a short LCM message.
 const shortMessageMagic = 0x4c433032
 
+// channel param keys.
+const (
+	// paramCompression is the key of the channel param with the compression of the message data.
+	paramCompression = "z"
+	// paramType is the key of the channel param with the full name of the proto message type of the message data.
+	paramType = "t"
+)
+
 // Message represents an LCM message.
 type Message struct {
 	Channel        string
@@ -44,26 +52,124 @@ type Message struct {
 	Data           []byte
 }
 
+// Param returns the value of the channel param with the provided key, or the empty string if there is no such param.
+//
+// Channel params are separated by '&', such as "z=lz4&t=google.protobuf.Timestamp".
+func (m *Message) Param(key string) string {
+	for param := range strings.SplitSeq(m.Params, "&") {
+		if k, v := split(param, '='); k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// compressionParam returns the compression channel param of the message, including its key.
+func (m *Message) compressionParam() (string, error) {
+	var compression string
+	for param := range strings.SplitSeq(m.Params, "&") {
+		if k, _ := split(param, '='); k != paramCompression {
+			continue
+		}
+		if compression != "" {
+			return "", fmt.Errorf("%w: multiple compression params: %s", ErrUnsupportedParams, m.Params)
+		}
+		compression = param
+	}
+	return compression, nil
+}
+
+// Clone returns a deep copy of the message, with data owned by the caller.
+func (m *Message) Clone() *Message {
+	clone := *m
+	clone.Data = bytes.Clone(m.Data)
+	return &clone
+}
+
+// ownedMessagePool is a pool of owned messages, reusing their data buffers.
+var ownedMessagePool = sync.Pool{
+	New: func() any {
+		return new(OwnedMessage)
+	},
+}
+
+// OwnedMessage is a received LCM message with data owned by the caller, drawn from a pool.
+//
+// Owned messages are safe to hand over to other goroutines. Release the message when done with it, to return its
+// buffer to the pool.
+type OwnedMessage struct {
+	Message
+	// released is set when the message is returned to the pool, and cleared when it is drawn from the pool again.
+	released bool
+}
+
+// newOwnedMessage returns an owned copy of the message from the pool.
+func newOwnedMessage(m *Message) *OwnedMessage {
+	owned := ownedMessagePool.Get().(*OwnedMessage)
+	owned.released = false
+	owned.Channel = m.Channel
+	owned.Params = m.Params
+	owned.SequenceNumber = m.SequenceNumber
+	owned.Data = append(owned.Data[:0], m.Data...)
+	return owned
+}
+
+// Release clears the message and returns it to the pool. The message, and its data, must not be used after being
+// released.
+//
+// Releasing a released message is a no-op, as long as the message has not been drawn from the pool again. A message
+// released twice by different owners, such as after being handed over to another goroutine, may corrupt the messages
+// of other receivers.
+func (m *OwnedMessage) Release() {
+	if m.released {
+		return
+	}
+	m.released = true
+	m.Channel = ""
+	m.Params = ""
+	m.SequenceNumber = 0
+	m.Data = m.Data[:0]
+	ownedMessagePool.Put(m)
+}
+
 // marshal an LCM message.
 func (m *Message) marshal(b []byte) (int, error) {
+	rawChannel, err := m.rawChannel()
+	if err != nil {
+		return 0, err
+	}
+	payloadSize := len(rawChannel) + 1 + len(m.Data)
+	if payloadSize > lengthOfLargestPayload {
+		return 0, fmt.Errorf("channel and data too long: %v bytes", payloadSize)
+	}
+	return m.put(b, rawChannel), nil
+}
+
+// rawChannel returns the channel of the message, followed by its params.
+func (m *Message) rawChannel() (string, error) {
 	rawChannel := m.Channel
 	if m.Params != "" {
 		rawChannel += "?" + m.Params
 	}
-	cLen := len(rawChannel)
-	if cLen > lengthOfLongestChannel {
-		return 0, fmt.Errorf("channel too long: %v bytes", len(m.Channel))
-	}
-	payloadSize := cLen + 1 + len(m.Data)
-	if payloadSize > lengthOfLargestPayload {
-		return 0, fmt.Errorf("channel and data too long: %v bytes", payloadSize)
+	if len(rawChannel) > lengthOfLongestChannel {
+		return "", fmt.Errorf("channel too long: %v bytes", len(m.Channel))
 	}
+	return rawChannel, nil
+}
+
+// marshaledLength returns the length in bytes of the marshaled message with the raw channel.
+func (m *Message) marshaledLength(rawChannel string) int {
+	return indexOfChannel + len(rawChannel) + 1 + len(m.Data)
+}
+
+// put the marshaled message with the raw channel into b, which must fit it, and return its length.
+func (m *Message) put(b []byte, rawChannel string) int {
 	binary.BigEndian.PutUint32(b[indexOfHeaderMagic:], shortMessageMagic)
 	binary.BigEndian.PutUint32(b[indexOfSequenceNumber:], m.SequenceNumber)
 	copy(b[indexOfChannel:], rawChannel)
-	b[indexOfChannel+cLen] = 0
-	copy(b[indexOfChannel+cLen+1:], m.Data)
-	return lengthOfHeaderMagic + lengthOfSequenceNumber + payloadSize, nil
+	b[indexOfChannel+len(rawChannel)] = 0
+	copy(b[indexOfChannel+len(rawChannel)+1:], m.Data)
+	return m.marshaledLength(rawChannel)
 }
 
 func split(s string, c byte) (string, string) {
@@ -77,16 +183,16 @@ func split(s string, c byte) (string, string) {
 // unmarshal an LCM message.
 func (m *Message) unmarshal(data []byte) error {
 	if len(data) < lengthOfSmallestMessage {
-		return fmt.Errorf("insufficient data: %v bytes", len(data))
+		return fmt.Errorf("%w: %v bytes", ErrInsufficientData, len(data))
 	}
 	header := binary.BigEndian.Uint32(data[indexOfHeaderMagic:])
 	if header != shortMessageMagic {
-		return fmt.Errorf("wrong header magic: 0x%x", header)
+		return fmt.Errorf("%w: 0x%x", ErrWrongHeaderMagic, header)
 	}
 	sequence := binary.BigEndian.Uint32(data[indexOfSequenceNumber:])
 	offsetOfNullByte := bytes.IndexByte(data[indexOfChannel:], 0)
 	if offsetOfNullByte == -1 {
-		return errors.New("invalid channel: not null-terminated")
+		return fmt.Errorf("%w: not null-terminated", ErrInvalidChannel)
 	}
 	indexOfPayload := indexOfChannel + offsetOfNullByte + 1
 	m.Channel, m.Params = split(string(data[indexOfChannel:indexOfPayload-1]), '?')
@@ -74,9 +74,10 @@ func TestMessage_MarshalUnmarshal(t *testing.T) {
 
 func TestMessage_Unmarshal_Errors(t *testing.T) {
 	for _, tt := range []struct {
-		msg  string
-		data []byte
-		err  string
+		msg      string
+		data     []byte
+		err      string
+		sentinel error
 	}{
 		{
 			msg: "invalid size",
@@ -84,7 +85,8 @@ func TestMessage_Unmarshal_Errors(t *testing.T) {
 				0x4c, 0x43, 0x30, 0x32, // short header magic
 				0x12, 0x34, 0x56,
 			},
-			err: "insufficient data: 7 bytes",
+			err:      "insufficient data: 7 bytes",
+			sentinel: ErrInsufficientData,
 		},
 		{
 			msg: "invalid channel",
@@ -93,7 +95,8 @@ func TestMessage_Unmarshal_Errors(t *testing.T) {
 				0x12, 0x34, 0x56, 0x78, // sequence number
 				'a', 'b', 'c', 'd', // channel (missing null byte)
 			},
-			err: "invalid channel: not null-terminated",
+			err:      "invalid channel: not null-terminated",
+			sentinel: ErrInvalidChannel,
 		},
 		{
 			msg: "invalid magic",
@@ -102,7 +105,8 @@ func TestMessage_Unmarshal_Errors(t *testing.T) {
 				0x12, 0x34, 0x56, 0x78, // sequence number
 				'a', 'b', 'c', 'd', // channel (missing null byte)
 			},
-			err: "wrong header magic: 0xdeadbeef",
+			err:      "wrong header magic: 0xdeadbeef",
+			sentinel: ErrWrongHeaderMagic,
 		},
 	} {
 		t.Run(tt.msg, func(t *testing.T) {
@@ -110,6 +114,68 @@ func TestMessage_Unmarshal_Errors(t *testing.T) {
 			err := msg.unmarshal(tt.data)
 			assert.Assert(t, err != nil)
 			assert.Equal(t, tt.err, err.Error())
+			assert.ErrorIs(t, err, tt.sentinel)
+		})
+	}
+}
+
+func TestMessage_Clone(t *testing.T) {
+	original := Message{Channel: "foo", Params: "z=lz4", SequenceNumber: 1, Data: []byte("bar")}
+	clone := original.Clone()
+	assert.DeepEqual(t, &original, clone)
+	original.Data[0] = 'c'
+	assert.DeepEqual(t, []byte("bar"), clone.Data)
+}
+
+func TestOwnedMessage_Release(t *testing.T) {
+	owned := newOwnedMessage(&Message{Channel: "foo", SequenceNumber: 1, Data: []byte("bar")})
+	assert.DeepEqual(t, Message{Channel: "foo", SequenceNumber: 1, Data: []byte("bar")}, owned.Message)
+	owned.Release()
+	// a released message should be cleared, and releasing it again should not return it to the pool twice
+	assert.DeepEqual(t, Message{Data: []byte{}}, owned.Message)
+	owned.Release()
+	// a reused owned message should not keep the fields of the released message
+	reused := newOwnedMessage(&Message{Channel: "baz", Data: []byte{}})
+	defer reused.Release()
+	assert.Equal(t, "baz", reused.Channel)
+	assert.Equal(t, uint32(0), reused.SequenceNumber)
+	assert.Equal(t, 0, len(reused.Data))
+	other := newOwnedMessage(&Message{Channel: "qux"})
+	defer other.Release()
+	assert.Assert(t, reused != other)
+}
+
+func TestMessage_Param(t *testing.T) {
+	for _, tt := range []struct {
+		msg         string
+		params      string
+		key         string
+		expected    string
+		compression string
+		err         error
+	}{
+		{msg: "no params", key: "t"},
+		{msg: "single param", params: "z=lz4", key: "z", expected: "lz4", compression: "z=lz4"},
+		{
+			msg:         "multiple params",
+			params:      "z=lz4&t=google.protobuf.Timestamp",
+			key:         "t",
+			expected:    "google.protobuf.Timestamp",
+			compression: "z=lz4",
+		},
+		{msg: "missing param", params: "t=google.protobuf.Timestamp", key: "z"},
+		{msg: "multiple compression params", params: "z=lz4&z=lz4", key: "z", expected: "lz4", err: ErrUnsupportedParams},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			m := Message{Params: tt.params}
+			assert.Equal(t, tt.expected, m.Param(tt.key))
+			compression, err := m.compressionParam()
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, tt.compression, compression)
 		})
 	}
 }
@@ -0,0 +1,46 @@
+package lcm
+
+import "time"
+
+// ReceiverMetrics receives instrumentation events from a Receiver.
+//
+// Implementations must be safe for concurrent use.
+type ReceiverMetrics interface {
+	// MessageReceived is called for every received message, with the size of the received datagram.
+	MessageReceived(channel string, sizeBytes int)
+	// DecodeFailed is called when a received datagram or proto payload can not be decoded.
+	//
+	// The channel is empty when the datagram is not a valid LCM message.
+	DecodeFailed(channel string)
+	// DecompressFailed is called when a received payload can not be decompressed.
+	DecompressFailed(channel string)
+	// BPFFallback is called when the configured BPF program can not be set on the socket, and filtering falls back
+	// to userspace.
+	BPFFallback(instructions int)
+}
+
+// TransmitterMetrics receives instrumentation events from a Transmitter.
+//
+// Implementations must be safe for concurrent use.
+type TransmitterMetrics interface {
+	// MessageTransmitted is called for every transmitted message, with the size of the transmitted datagram and the
+	// duration of the transmit call.
+	MessageTransmitted(channel string, sizeBytes int, duration time.Duration)
+	// TransmitFailed is called when a message can not be transmitted.
+	TransmitFailed(channel string)
+}
+
+// noopMetrics is a no-op implementation of ReceiverMetrics and TransmitterMetrics.
+type noopMetrics struct{}
+
+var (
+	_ ReceiverMetrics    = noopMetrics{}
+	_ TransmitterMetrics = noopMetrics{}
+)
+
+func (noopMetrics) MessageReceived(string, int)                   {}
+func (noopMetrics) DecodeFailed(string)                           {}
+func (noopMetrics) DecompressFailed(string)                       {}
+func (noopMetrics) BPFFallback(int)                               {}
+func (noopMetrics) MessageTransmitted(string, int, time.Duration) {}
+func (noopMetrics) TransmitFailed(string)                         {}
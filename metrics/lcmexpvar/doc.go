@@ -0,0 +1,2 @@
+// Package lcmexpvar provides expvar-based metrics for LCM receivers and transmitters.
+package lcmexpvar
@@ -0,0 +1,83 @@
+package lcmexpvar
+
+import (
+	"expvar"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLatencyBuckets are the upper bounds of the latency histogram buckets.
+var defaultLatencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+var _ expvar.Var = &histogram{}
+
+// histogram is a latency histogram with fixed buckets.
+type histogram struct {
+	buckets []time.Duration
+	// counts are the number of observations per bucket, with an extra bucket for observations above the largest
+	// bucket bound.
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    atomic.Int64
+}
+
+// newHistogram returns a new histogram with the provided bucket upper bounds.
+func newHistogram(buckets []time.Duration) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]atomic.Uint64, len(buckets)+1),
+	}
+}
+
+// observe a duration.
+func (h *histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.buckets), func(i int) bool {
+		return d <= h.buckets[i]
+	})
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// String returns the histogram as a JSON object with cumulative bucket counts, implementing expvar.Var.
+func (h *histogram) String() string {
+	var b strings.Builder
+	b.WriteString(`{"count":`)
+	b.WriteString(strconv.FormatUint(h.count.Load(), 10))
+	b.WriteString(`,"sum":`)
+	b.WriteString(strconv.FormatFloat(time.Duration(h.sum.Load()).Seconds(), 'g', -1, 64))
+	b.WriteString(`,"buckets":[`)
+	var cumulative uint64
+	for i := range h.counts {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		cumulative += h.counts[i].Load()
+		b.WriteString(`{"le":`)
+		if i < len(h.buckets) {
+			b.WriteString(strconv.FormatFloat(h.buckets[i].Seconds(), 'g', -1, 64))
+		} else {
+			b.WriteString(`"+Inf"`)
+		}
+		b.WriteString(`,"count":`)
+		b.WriteString(strconv.FormatUint(cumulative, 10))
+		b.WriteByte('}')
+	}
+	b.WriteString(`]}`)
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package lcmexpvar
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"go.einride.tech/lcm"
+)
+
+var (
+	_ lcm.ReceiverMetrics    = &Metrics{}
+	_ lcm.TransmitterMetrics = &Metrics{}
+	_ expvar.Var             = &Metrics{}
+)
+
+// Metrics is an expvar-based implementation of LCM receiver and transmitter metrics.
+//
+// Publish the metrics with expvar.Publish to expose them through the expvar HTTP handler.
+type Metrics struct {
+	vars                expvar.Map
+	messagesReceived    expvar.Map
+	bytesReceived       expvar.Map
+	decodeErrors        expvar.Map
+	decompressErrors    expvar.Map
+	bpfFallbacks        expvar.Int
+	messagesTransmitted expvar.Map
+	bytesTransmitted    expvar.Map
+	transmitErrors      expvar.Map
+	transmitLatency     expvar.Map
+	mu                  sync.Mutex
+	latencyHistograms   map[string]*histogram
+}
+
+// NewMetrics returns new expvar-based metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{latencyHistograms: make(map[string]*histogram)}
+	m.vars.Set("messages_received", &m.messagesReceived)
+	m.vars.Set("bytes_received", &m.bytesReceived)
+	m.vars.Set("decode_errors", &m.decodeErrors)
+	m.vars.Set("decompress_errors", &m.decompressErrors)
+	m.vars.Set("bpf_fallbacks", &m.bpfFallbacks)
+	m.vars.Set("messages_transmitted", &m.messagesTransmitted)
+	m.vars.Set("bytes_transmitted", &m.bytesTransmitted)
+	m.vars.Set("transmit_errors", &m.transmitErrors)
+	m.vars.Set("transmit_latency_seconds", &m.transmitLatency)
+	return m
+}
+
+// String returns the metrics as a JSON object, implementing expvar.Var.
+func (m *Metrics) String() string {
+	return m.vars.String()
+}
+
+// MessageReceived implements lcm.ReceiverMetrics.
+func (m *Metrics) MessageReceived(channel string, sizeBytes int) {
+	m.messagesReceived.Add(channel, 1)
+	m.bytesReceived.Add(channel, int64(sizeBytes))
+}
+
+// DecodeFailed implements lcm.ReceiverMetrics.
+func (m *Metrics) DecodeFailed(channel string) {
+	m.decodeErrors.Add(channel, 1)
+}
+
+// DecompressFailed implements lcm.ReceiverMetrics.
+func (m *Metrics) DecompressFailed(channel string) {
+	m.decompressErrors.Add(channel, 1)
+}
+
+// BPFFallback implements lcm.ReceiverMetrics.
+func (m *Metrics) BPFFallback(int) {
+	m.bpfFallbacks.Add(1)
+}
+
+// MessageTransmitted implements lcm.TransmitterMetrics.
+func (m *Metrics) MessageTransmitted(channel string, sizeBytes int, duration time.Duration) {
+	m.messagesTransmitted.Add(channel, 1)
+	m.bytesTransmitted.Add(channel, int64(sizeBytes))
+	m.latencyHistogram(channel).observe(duration)
+}
+
+// TransmitFailed implements lcm.TransmitterMetrics.
+func (m *Metrics) TransmitFailed(channel string) {
+	m.transmitErrors.Add(channel, 1)
+}
+
+// latencyHistogram returns the transmit latency histogram for the provided channel.
+func (m *Metrics) latencyHistogram(channel string) *histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.latencyHistograms[channel]
+	if !ok {
+		h = newHistogram(defaultLatencyBuckets)
+		m.latencyHistograms[channel] = h
+		m.transmitLatency.Set(channel, h)
+	}
+	return h
+}
@@ -0,0 +1,39 @@
+package lcmexpvar
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMetrics_String(t *testing.T) {
+	m := NewMetrics()
+	m.MessageReceived("foo", 10)
+	m.MessageReceived("foo", 20)
+	m.DecodeFailed("")
+	m.DecompressFailed("foo")
+	m.BPFFallback(300)
+	m.MessageTransmitted("bar", 30, 20*time.Microsecond)
+	m.MessageTransmitted("bar", 30, 2*time.Second)
+	m.TransmitFailed("bar")
+	var actual map[string]any
+	assert.NilError(t, json.Unmarshal([]byte(m.String()), &actual))
+	assert.DeepEqual(t, map[string]any{"foo": 2.0}, actual["messages_received"])
+	assert.DeepEqual(t, map[string]any{"foo": 30.0}, actual["bytes_received"])
+	assert.DeepEqual(t, map[string]any{"": 1.0}, actual["decode_errors"])
+	assert.DeepEqual(t, map[string]any{"foo": 1.0}, actual["decompress_errors"])
+	assert.DeepEqual(t, 1.0, actual["bpf_fallbacks"])
+	assert.DeepEqual(t, map[string]any{"bar": 2.0}, actual["messages_transmitted"])
+	assert.DeepEqual(t, map[string]any{"bar": 60.0}, actual["bytes_transmitted"])
+	assert.DeepEqual(t, map[string]any{"bar": 1.0}, actual["transmit_errors"])
+	latency := actual["transmit_latency_seconds"].(map[string]any)["bar"].(map[string]any)
+	assert.Equal(t, 2.0, latency["count"])
+	assert.Equal(t, 2.00002, latency["sum"])
+	buckets := latency["buckets"].([]any)
+	assert.DeepEqual(t, map[string]any{"le": 1e-05, "count": 0.0}, buckets[0])
+	assert.DeepEqual(t, map[string]any{"le": 5e-05, "count": 1.0}, buckets[1])
+	assert.DeepEqual(t, map[string]any{"le": 1.0, "count": 1.0}, buckets[len(buckets)-2])
+	assert.DeepEqual(t, map[string]any{"le": "+Inf", "count": 2.0}, buckets[len(buckets)-1])
+}
@@ -0,0 +1,270 @@
+package lcm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"go.einride.tech/lcm/lcmbpf"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+)
+
+// subscriberQueueSize is the number of received messages queued for each subscriber of a node.
+//
+// When the queue of a subscriber is full, receiving blocks until the subscriber catches up.
+const subscriberQueueSize = 64
+
+// Node groups the publishers and subscribers of a service, sharing one receiver and one transmitter.
+//
+// Safe for concurrent use.
+type Node struct {
+	rx *Receiver
+	tx *Transmitter
+	// mu protects the publishers, the subscribers and the running state.
+	mu          sync.Mutex
+	publishers  map[string]string
+	subscribers []*subscriber
+	running     bool
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+// NodeChannel is a channel published or subscribed to by a node.
+type NodeChannel struct {
+	// Name is the name, or the pattern, of the channel.
+	Name string
+	// Type is the full name of the proto message type of the channel, if any.
+	Type string
+}
+
+// subscriber is a handler of the messages on a channel pattern, with a queue of received messages.
+type subscriber struct {
+	pattern  string
+	typeName string
+	handler  func(context.Context, *Message) error
+	queue    chan *OwnedMessage
+}
+
+// NewNode returns a Node of the provided receiver and transmitter.
+//
+// The node takes ownership of the receiver and the transmitter, and closes them when closed.
+func NewNode(rx *Receiver, tx *Transmitter) *Node {
+	return &Node{rx: rx, tx: tx, publishers: make(map[string]string)}
+}
+
+// Receiver returns the receiver of the node.
+func (n *Node) Receiver() *Receiver {
+	return n.rx
+}
+
+// Transmitter returns the transmitter of the node.
+func (n *Node) Transmitter() *Transmitter {
+	return n.tx
+}
+
+// Subscribe calls the handler with the messages received on channels matching the pattern, which may contain '*'
+// wildcards.
+//
+// Each subscriber is called from its own goroutine while the node is running, and owns the messages until the
+// handler returns. Subscribers must be added before the node is run.
+func (n *Node) Subscribe(ctx context.Context, pattern string, handler func(context.Context, *Message) error) error {
+	return n.subscribe(ctx, pattern, "", handler)
+}
+
+func (n *Node) subscribe(
+	ctx context.Context, pattern, typeName string, handler func(context.Context, *Message) error,
+) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.running {
+		return fmt.Errorf("subscribe to %s: node is running", pattern)
+	}
+	if err := n.rx.AddChannels(ctx, pattern); err != nil {
+		return fmt.Errorf("subscribe to %s: %w", pattern, err)
+	}
+	n.subscribers = append(n.subscribers, &subscriber{
+		pattern:  pattern,
+		typeName: typeName,
+		handler:  handler,
+		queue:    make(chan *OwnedMessage, subscriberQueueSize),
+	})
+	return nil
+}
+
+// SubscribeProto calls the handler with the proto messages received on channels matching the pattern, decoded into
+// new messages of type T owned by the handler.
+//
+// An empty pattern subscribes to the channel given by the fully-qualified name of T.
+func SubscribeProto[T proto.Message](
+	ctx context.Context, n *Node, pattern string, handler func(context.Context, T) error,
+) error {
+	var zero T
+	name := string(zero.ProtoReflect().Descriptor().FullName())
+	if pattern == "" {
+		pattern = name
+	}
+	return n.subscribe(ctx, pattern, name, func(ctx context.Context, m *Message) error {
+		msg := zero.ProtoReflect().New().Interface().(T)
+		if err := n.rx.decodeProtoMessage(ctx, m, msg, false); err != nil {
+			return err
+		}
+		return handler(ctx, msg)
+	})
+}
+
+// Publisher publishes proto messages of type T on a channel of a node.
+type Publisher[T proto.Message] struct {
+	node    *Node
+	channel string
+}
+
+// NewPublisher returns a Publisher of proto messages of type T on the channel of the node.
+//
+// An empty channel publishes on the channel given by the fully-qualified name of T.
+func NewPublisher[T proto.Message](n *Node, channel string) *Publisher[T] {
+	var zero T
+	name := string(zero.ProtoReflect().Descriptor().FullName())
+	if channel == "" {
+		channel = name
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.publishers[channel] = name
+	return &Publisher[T]{node: n, channel: channel}
+}
+
+// Channel returns the channel of the publisher.
+func (p *Publisher[T]) Channel() string {
+	return p.channel
+}
+
+// Publish a proto message.
+func (p *Publisher[T]) Publish(ctx context.Context, m T) error {
+	return p.node.tx.TransmitProtoOnChannel(ctx, p.channel, m)
+}
+
+// Publications returns the channels published by the publishers of the node, sorted by name.
+func (n *Node) Publications() []NodeChannel {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	channels := make([]NodeChannel, 0, len(n.publishers))
+	for channel, typeName := range n.publishers {
+		channels = append(channels, NodeChannel{Name: channel, Type: typeName})
+	}
+	slices.SortFunc(channels, compareNodeChannels)
+	return channels
+}
+
+// Subscriptions returns the channel patterns subscribed to by the subscribers of the node, sorted by name.
+func (n *Node) Subscriptions() []NodeChannel {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	channels := make([]NodeChannel, 0, len(n.subscribers))
+	for _, s := range n.subscribers {
+		channels = append(channels, NodeChannel{Name: s.pattern, Type: s.typeName})
+	}
+	slices.SortFunc(channels, compareNodeChannels)
+	return slices.Compact(channels)
+}
+
+func compareNodeChannels(a, b NodeChannel) int {
+	return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Type, b.Type))
+}
+
+// Run receives messages and dispatches them to the subscribers, with one goroutine per subscriber, until the
+// provided context is canceled or a receive or a subscriber returns an error.
+//
+// Run returns nil when the provided context is canceled, unless a receive or a subscriber has already failed. A node
+// can only be run once.
+func (n *Node) Run(ctx context.Context) error {
+	n.mu.Lock()
+	if n.running {
+		n.mu.Unlock()
+		return errors.New("run node: node is already running")
+	}
+	n.running = true
+	subscribers := n.subscribers
+	n.mu.Unlock()
+	g, gctx := errgroup.WithContext(ctx)
+	for _, s := range subscribers {
+		g.Go(func() error {
+			return n.runSubscriber(gctx, s)
+		})
+	}
+	g.Go(func() error {
+		return n.dispatch(gctx, subscribers)
+	})
+	err := g.Wait()
+	for _, s := range subscribers {
+		s.releaseQueue()
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("run node: %w", err)
+	}
+	return nil
+}
+
+// dispatch received messages to the queues of the matching subscribers.
+func (n *Node) dispatch(ctx context.Context, subscribers []*subscriber) error {
+	for {
+		if err := n.rx.Receive(ctx); err != nil {
+			return err
+		}
+		m := n.rx.Message()
+		for _, s := range subscribers {
+			if !lcmbpf.MatchChannel(s.pattern, m.Channel) {
+				continue
+			}
+			owned := newOwnedMessage(m)
+			select {
+			case s.queue <- owned:
+			case <-ctx.Done():
+				owned.Release()
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// releaseQueue releases the messages left in the queue of the subscriber.
+func (s *subscriber) releaseQueue() {
+	for {
+		select {
+		case owned := <-s.queue:
+			owned.Release()
+		default:
+			return
+		}
+	}
+}
+
+// runSubscriber calls the handler of the subscriber with the queued messages.
+func (n *Node) runSubscriber(ctx context.Context, s *subscriber) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case owned := <-s.queue:
+			err := s.handler(ctx, &owned.Message)
+			owned.Release()
+			if err != nil && !n.rx.skipMalformed(err) {
+				return err
+			}
+		}
+	}
+}
+
+// Close the receiver and the transmitter of the node.
+//
+// Close stops a running node with an error, and is safe to call after Run returns. Closing a closed node returns the
+// result of the first Close.
+func (n *Node) Close() error {
+	n.closeOnce.Do(func() {
+		n.closeErr = errors.Join(n.rx.Close(), n.tx.Close())
+	})
+	return n.closeErr
+}
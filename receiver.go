@@ -2,12 +2,18 @@ package lcm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"runtime"
-	"strings"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 
 	"go.einride.tech/lcm/compression/lcmlz4"
+	"go.einride.tech/lcm/lcmbpf"
 	"golang.org/x/net/bpf"
 	"golang.org/x/net/ipv4"
 	"google.golang.org/protobuf/proto"
@@ -19,51 +25,79 @@ type Decompressor interface {
 }
 
 // ListenMulticastUDP returns a Receiver configured with the provided options.
-func ListenMulticastUDP(ctx context.Context, receiverOpts ...ReceiverOption) (*Receiver, error) {
+func ListenMulticastUDP(ctx context.Context, receiverOpts ...ReceiverOption) (_ *Receiver, err error) {
 	opts := defaultReceiverOptions()
 	for _, receiverOpt := range receiverOpts {
 		receiverOpt(opts)
 	}
 	var listenConfig net.ListenConfig
-	// wildcard address prefix for all administratively-scoped (local) multicast addresses
-	packetConn, err := listenConfig.ListenPacket(ctx, "udp4", fmt.Sprintf("239.0.0.0:%d", opts.port))
+	if opts.shards > 0 {
+		listenConfig.Control = setReusePort
+	}
+	packetConn, err := listenConfig.ListenPacket(ctx, "udp4", opts.listenAddress())
 	if err != nil {
 		return nil, fmt.Errorf("opening packet listener: %w", err)
 	}
 	udpConn := packetConn.(*net.UDPConn)
+	// closeConn closes the socket on errors, after leaving the joined groups once the receiver is created
+	closeConn := udpConn.Close
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, closeConn())
+		}
+	}()
 	if err := udpConn.SetReadBuffer(opts.bufferSizeBytes); err != nil {
 		return nil, fmt.Errorf("setting read buffer: %w", err)
 	}
+	if err := enableDropCounter(udpConn); err != nil {
+		return nil, fmt.Errorf("setting drop counter: %w", err)
+	}
+	if opts.shards > 1 && opts.shard == 0 {
+		// the socket selection program applies to the whole reuse port group, including sockets bound later
+		program, err := lcmbpf.ReusePortChannelShard(opts.shards)
+		if err != nil {
+			return nil, fmt.Errorf("assembling reuse port bpf: %w", err)
+		}
+		rawBPFInstructions, err := bpf.Assemble(program)
+		if err != nil {
+			return nil, fmt.Errorf("assembling reuse port bpf: %w", err)
+		}
+		if err := attachReusePortBPF(udpConn, rawBPFInstructions); err != nil {
+			return nil, fmt.Errorf("setting reuse port bpf: %w", err)
+		}
+	}
 	conn := ipv4.NewPacketConn(udpConn)
-	if len(opts.ips) == 0 {
-		opts.ips = append(opts.ips, DefaultMulticastIP())
+	if len(opts.ips) == 0 && len(opts.sources) == 0 {
+		if opts.bindAddress.IsMulticast() {
+			opts.ips = append(opts.ips, opts.bindAddress)
+		} else {
+			opts.ips = append(opts.ips, DefaultMulticastIP())
+		}
 	}
 	rx := &Receiver{
 		conn:          conn,
 		opts:          opts,
+		subscription:  newChannelSubscription(opts.channels, opts.protos),
+		interfaces:    make(map[int]*net.Interface),
 		protoMessages: make(map[string]proto.Message),
 		decompressors: map[string]Decompressor{"z=lz4": lcmlz4.NewDecompressor()},
 	}
-	if opts.interfaceName != "" {
-		ifi, err := net.InterfaceByName(opts.interfaceName)
+	closeConn = rx.close
+	if opts.joinsMulticastGroups() {
+		ifis, err := opts.receiveInterfaces()
 		if err != nil {
-			return nil, fmt.Errorf("getting %s interface by name: %w", opts.interfaceName, err)
+			return nil, err
 		}
-		if ifi.Flags&net.FlagMulticast == 0 {
-			return nil, fmt.Errorf("interface %s is not a multicast interface", ifi.Name)
+		if len(ifis) == 0 {
+			if opts.interfaceRefreshInterval() == 0 {
+				return nil, errors.New("listen multicast UDP: no up multicast interfaces to receive on")
+			}
+			opts.logger.LogAttrs(
+				ctx, slog.LevelWarn, "no up multicast interfaces to receive on, waiting for interfaces to appear",
+			)
 		}
-		if ifi.Flags&net.FlagUp == 0 {
-			return nil, fmt.Errorf("interface %s is not up", ifi.Name)
-		}
-		rx.ifi = ifi
-	}
-	for _, ip := range opts.ips {
-		// from: https://godoc.org/golang.org/x/net/ipv4#hdr-Multicasting
-		//
-		// Note that the service port for transport layer protocol does not matter with this operation as joining
-		// groups affects only network and link layer protocols, such as IPv4 and Ethernet.
-		if err := conn.JoinGroup(rx.ifi, &net.UDPAddr{IP: ip}); err != nil {
-			return nil, fmt.Errorf("joining multicast group: IP %v: %w", ip, err)
+		if err := rx.joinInterfaces(ctx, ifis); err != nil {
+			return nil, err
 		}
 	}
 	// contralFlags are the control flags used to configure the LCM connection.
@@ -71,16 +105,10 @@ func ListenMulticastUDP(ctx context.Context, receiverOpts ...ReceiverOption) (*R
 	if err := conn.SetControlMessage(controlFlags, true); err != nil {
 		return nil, fmt.Errorf("setting control message: %w", err)
 	}
-	if runtime.GOOS == "linux" && len(opts.bpfProgram) > 0 && len(opts.bpfProgram) < 256 {
-		rawBPFInstructions, err := bpf.Assemble(opts.bpfProgram)
-		if err != nil {
-			return nil, fmt.Errorf("assembling bpf: %w", err)
-		}
-		if err := conn.SetBPF(rawBPFInstructions); err != nil {
-			return nil, fmt.Errorf("setting bpf: %w", err)
-		}
+	if err := rx.setBPF(ctx, rx.subscription); err != nil {
+		return nil, err
 	}
-	for _, msg := range opts.protos {
+	for _, msg := range opts.registeredProtos() {
 		// TODO: Should we perform validation here?
 		name := msg.ProtoReflect().Descriptor().FullName()
 		rx.protoMessages[string(name)] = proto.Clone(msg)
@@ -91,100 +119,497 @@ func ListenMulticastUDP(ctx context.Context, receiverOpts ...ReceiverOption) (*R
 			Buffers: [][]byte{
 				make([]byte, lengthOfLargestUDPMessage),
 			},
-			OOB: ipv4.NewControlMessage(controlFlags),
+			OOB: append(ipv4.NewControlMessage(controlFlags), make([]byte, lengthOfDropCounterControlMessage)...),
 		})
 	}
+	opts.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"listening for LCM messages",
+		slog.String("address", udpConn.LocalAddr().String()),
+		slog.Int("interfaces", len(rx.interfaces)),
+		slog.Int("bufferSize", opts.bufferSizeBytes),
+		slog.Int("batchSize", opts.batchSize),
+	)
+	if interval := opts.interfaceRefreshInterval(); interval > 0 && opts.joinsMulticastGroups() {
+		rx.closed = make(chan struct{})
+		rx.refreshDone = make(chan struct{})
+		go rx.refreshInterfaces(interval)
+	}
 	return rx, nil
 }
 
+// ListenUDP returns a Receiver for unicast and broadcast datagrams, configured with the provided options.
+//
+// The receiver is bound to the unspecified address unless configured with WithReceiveBindAddress, and does not join
+// any multicast groups.
+func ListenUDP(ctx context.Context, receiverOpts ...ReceiverOption) (*Receiver, error) {
+	return ListenMulticastUDP(ctx, append(slices.Clone(receiverOpts), withReceiveUnicast())...)
+}
+
 // Receiver represents an LCM Receiver instance.
 //
-// Not thread-safe.
+// Not thread-safe, except for Stats and the methods changing the subscribed channels.
 type Receiver struct {
-	opts            *receiverOptions
-	conn            *ipv4.PacketConn
-	ifi             *net.Interface
+	opts     *receiverOptions
+	conn     *ipv4.PacketConn
+	unixConn *net.UnixConn
+	// unixPath is the path of the socket of a Unix datagram receiver, which may differ from its bound address.
+	unixPath        string
+	shm             *shmReader
 	messageBuf      []ipv4.Message
 	messageBufSize  int
 	messageBufIndex int
 	currMessage     Message
+	rawMessage      []byte
 	dstAddr         net.IP
 	srcAddr         net.IP
+	source          senderSource
 	ifIndex         int
-	protoMessages   map[string]proto.Message
-	protoMessage    proto.Message
-	decompressors   map[string]Decompressor
+	// mu protects the subscription, the proto messages, the BPF program and the joined interfaces.
+	mu            sync.Mutex
+	interfaces    map[int]*net.Interface
+	closed        chan struct{}
+	refreshDone   chan struct{}
+	closeOnce     sync.Once
+	closeErr      error
+	subscription  *channelSubscription
+	protoMessages map[string]proto.Message
+	bpfSet        bool
+	protoMessage  proto.Message
+	decompressors map[string]Decompressor
+	stats         receiverStats
+	dropCounter   uint32
 }
 
 // Receive an LCM message.
 //
-// If the provided context has a deadline, it will be propagated to the underlying read operation.
+// If the provided context has a deadline, it will be propagated to the underlying read operation. If the provided
+// context is canceled while waiting for a datagram, the read is interrupted and Receive returns the context error,
+// the same as when the deadline expires.
+//
+// Datagrams that are not valid LCM messages, or that can not be decompressed, result in a *MalformedMessageError,
+// unless the receiver is configured to skip malformed messages.
 func (r *Receiver) Receive(ctx context.Context) error {
+	return r.receiveNext(ctx, false)
+}
+
+// ReceiveRaw receives an LCM message without decompressing it.
+//
+// The message is filtered like with Receive, but its data is left as transmitted, with the compression params intact.
+// The original datagram is available from RawMessage, for relays and taps that forward messages without decoding
+// them.
+func (r *Receiver) ReceiveRaw(ctx context.Context) error {
+	return r.receiveNext(ctx, true)
+}
+
+// receiveNext receives the next LCM message on a subscribed channel, skipping malformed messages if configured to.
+func (r *Receiver) receiveNext(ctx context.Context, raw bool) error {
+	for {
+		ok, err := r.receive(ctx, raw)
+		if err != nil {
+			if r.skipMalformed(err) {
+				continue
+			}
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+}
+
+// receive the next LCM message, and report if it is on a subscribed channel.
+//
+// Raw messages are not decompressed.
+func (r *Receiver) receive(ctx context.Context, raw bool) (bool, error) {
 	r.protoMessage = nil
+	r.rawMessage = nil
 	if r.messageBufIndex >= r.messageBufSize {
 		r.messageBufIndex = 0
-		deadline, _ := ctx.Deadline()
-		if err := r.conn.SetReadDeadline(deadline); err != nil {
-			return fmt.Errorf("receive on LCM: %w", err)
-		}
-		n, err := r.conn.ReadBatch(r.messageBuf, 0)
+		r.messageBufSize = 0
+		n, err := r.readBatch(ctx)
 		if err != nil {
-			return fmt.Errorf("receive on LCM: %w", err)
+			return false, fmt.Errorf("receive on LCM: %w", err)
 		}
 		r.messageBufSize = n
+		r.observeDiscards()
 	}
 	curr := r.messageBuf[r.messageBufIndex]
 	r.messageBufIndex++
 	var cm ipv4.ControlMessage
 	if err := cm.Parse(curr.OOB[:curr.NN]); err != nil {
-		return fmt.Errorf("receive on LCM: %w", err)
+		return false, fmt.Errorf("receive on LCM: %w", err)
 	}
 	r.srcAddr = cm.Src
 	r.dstAddr = cm.Dst
 	r.ifIndex = cm.IfIndex
-	if err := r.currMessage.unmarshal(curr.Buffers[0][:curr.N]); err != nil {
-		return fmt.Errorf("receive on LCM: %w", err)
+	if r.opts.bindAddress.IsMulticast() && cm.Dst != nil && !cm.Dst.Equal(r.opts.bindAddress) {
+		return false, nil // not filtered by the kernel
+	}
+	r.rawMessage = curr.Buffers[0][:curr.N]
+	if err := r.currMessage.unmarshal(r.rawMessage); err != nil {
+		r.opts.metrics.DecodeFailed("")
+		r.opts.logger.LogAttrs(
+			ctx, slog.LevelDebug, "failed to decode message", slog.Any("source", curr.Addr), slog.Any("error", err),
+		)
+		return false, fmt.Errorf("receive on LCM: %w", &MalformedMessageError{Source: curr.Addr, Err: err})
+	}
+	r.source = senderSource{}
+	switch addr := curr.Addr.(type) {
+	case *net.UDPAddr:
+		r.source.addrPort = addr.AddrPort()
+	case *net.UnixAddr:
+		if addr != nil {
+			r.source.path = addr.Name
+		}
+	}
+	r.stats.observe(r.source, r.currMessage.Channel, r.currMessage.SequenceNumber, time.Now())
+	if !r.isSubscribed(r.currMessage.Channel) {
+		return false, nil // not filtered by the kernel, or received before the BPF program was replaced
+	}
+	if raw {
+		r.opts.metrics.MessageReceived(r.currMessage.Channel, curr.N)
+		return true, nil
 	}
-	params := strings.Split(r.currMessage.Params, "&")
-	if len(params) > 1 {
-		return fmt.Errorf("receive multiple query params not supported")
+	compression, err := r.currMessage.compressionParam()
+	if err != nil {
+		r.opts.metrics.DecodeFailed(r.currMessage.Channel)
+		r.opts.logger.LogAttrs(
+			ctx,
+			slog.LevelDebug,
+			"failed to decode message params",
+			slog.String("channel", r.currMessage.Channel),
+			slog.String("params", r.currMessage.Params),
+		)
+		return false, fmt.Errorf("receive on LCM: %w", &MalformedMessageError{
+			Source:  curr.Addr,
+			Channel: r.currMessage.Channel,
+			Err:     err,
+		})
 	}
-	if decompressor, ok := r.decompressors[params[0]]; ok {
+	if decompressor, ok := r.decompressors[compression]; ok {
 		data, err := decompressor.Decompress(r.currMessage.Data)
 		if err != nil {
-			return fmt.Errorf("decompressor on LCM: %w", err)
+			r.opts.metrics.DecompressFailed(r.currMessage.Channel)
+			r.opts.logger.LogAttrs(
+				ctx,
+				slog.LevelDebug,
+				"failed to decompress message",
+				slog.String("channel", r.currMessage.Channel),
+				slog.Any("error", err),
+			)
+			return false, fmt.Errorf("decompressor on LCM: %w", &MalformedMessageError{
+				Source:  curr.Addr,
+				Channel: r.currMessage.Channel,
+				Err:     err,
+			})
 		}
 		r.currMessage.Data = data
 	}
-	return nil
+	r.opts.metrics.MessageReceived(r.currMessage.Channel, curr.N)
+	return true, nil
+}
+
+// readBatch reads a batch of datagrams into the message buffer, interrupting the read when the context is done.
+func (r *Receiver) readBatch(ctx context.Context) (int, error) {
+	if r.shm != nil {
+		return r.readSharedMemory(ctx)
+	}
+	deadline, _ := ctx.Deadline()
+	if err := r.setReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+	if ctx.Done() != nil {
+		interrupted := make(chan struct{})
+		stop := context.AfterFunc(ctx, func() {
+			_ = r.setReadDeadline(time.Now())
+			close(interrupted)
+		})
+		defer func() {
+			if !stop() {
+				<-interrupted // don't let the interrupt leak into the next read
+			}
+		}()
+	}
+	var n int
+	var err error
+	if r.unixConn != nil {
+		n, err = r.readUnixgram()
+	} else {
+		n, err = r.conn.ReadBatch(r.messageBuf, 0)
+	}
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return 0, ctx.Err()
+	}
+	return n, err
+}
+
+// setReadDeadline sets the read deadline of the receiver socket.
+func (r *Receiver) setReadDeadline(t time.Time) error {
+	if r.unixConn != nil {
+		return r.unixConn.SetReadDeadline(t)
+	}
+	return r.conn.SetReadDeadline(t)
+}
+
+// observeDiscards updates the kernel discard statistics from the control messages of the current batch, or the records
+// skipped by a shared-memory receiver.
+func (r *Receiver) observeDiscards() {
+	var batchDiscarded uint64
+	if r.shm != nil {
+		batchDiscarded = r.shm.skipped
+	}
+	for _, m := range r.messageBuf[:r.messageBufSize] {
+		if counter, ok := parseDropCounter(m.OOB[:m.NN]); ok {
+			batchDiscarded += uint64(counter - r.dropCounter)
+			r.dropCounter = counter
+		}
+	}
+	r.stats.observeDiscards(batchDiscarded)
 }
 
-// Receive a proto LCM message. The channel is assumed to be a fully-qualified message name.
+// skipMalformed counts malformed message errors and reports if they should be skipped.
+func (r *Receiver) skipMalformed(err error) bool {
+	var malformedErr *MalformedMessageError
+	if !errors.As(err, &malformedErr) {
+		return false
+	}
+	r.stats.observeMalformed()
+	return r.opts.skipMalformed
+}
+
+// Receive a proto LCM message.
+//
+// The proto message type is given by the "t" channel param of the message, or by the channel as configured with
+// WithReceiveProtoOnChannel. Otherwise, the channel is assumed to be a fully-qualified message name. Messages of types
+// not configured with WithReceiveProtos or WithReceiveProtoOnChannel are received without a proto message.
+//
+// Only messages on subscribed channels are received. Messages with a type param on channels other than the full name
+// of their type are not subscribed to by WithReceiveProtos or AddProtos, and their channels must be subscribed to
+// explicitly with WithReceiveChannels or AddChannels.
+//
+// Proto payloads that can not be decoded result in a *MalformedMessageError, unless the receiver is configured to
+// skip malformed messages.
 func (r *Receiver) ReceiveProto(ctx context.Context) error {
-	if err := r.Receive(ctx); err != nil {
-		return err
+	for {
+		if err := r.Receive(ctx); err != nil {
+			return err
+		}
+		if err := r.unmarshalProto(ctx); err != nil {
+			if r.skipMalformed(err) {
+				continue
+			}
+			return err
+		}
+		return nil
 	}
-	protoMessage, ok := r.protoMessages[r.currMessage.Channel]
+}
+
+// unmarshalProto unmarshals the last received message into the proto message registered for its channel.
+func (r *Receiver) unmarshalProto(ctx context.Context) error {
+	r.mu.Lock()
+	protoMessage, ok := r.protoMessages[r.protoType()]
+	r.mu.Unlock()
 	if !ok {
 		return nil // ignore messages we aren't listening to
 	}
-	if err := proto.Unmarshal(r.currMessage.Data, protoMessage); err != nil {
-		return fmt.Errorf("receive proto %s on LCM: %w", r.currMessage.Channel, err)
+	if err := r.decodeProto(ctx, protoMessage, false); err != nil {
+		return err
 	}
 	r.protoMessage = protoMessage
 	return nil
 }
 
+// protoType returns the full name of the proto message type of the last received message.
+func (r *Receiver) protoType() string {
+	if name := r.currMessage.Param(paramType); name != "" {
+		return name
+	}
+	if name, ok := r.opts.protoChannels[r.currMessage.Channel]; ok {
+		return name
+	}
+	return r.currMessage.Channel
+}
+
+// AddChannels subscribes to additional channels, which may contain '*' wildcards.
+//
+// The BPF program of the receiver is rebuilt and replaced without reopening the socket.
+func (r *Receiver) AddChannels(ctx context.Context, patterns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription := r.subscription.clone()
+	for _, pattern := range patterns {
+		subscription.add(pattern, subscribedChannel)
+	}
+	if err := r.setBPF(ctx, subscription); err != nil {
+		return fmt.Errorf("add channels to LCM receiver: %w", err)
+	}
+	r.subscription = subscription
+	return nil
+}
+
+// RemoveChannels unsubscribes from channels previously subscribed to with the exact same patterns.
+//
+// Channels also subscribed to as the channels of proto messages remain subscribed. When the last channel is removed,
+// the receiver receives no messages until channels are added again.
+func (r *Receiver) RemoveChannels(ctx context.Context, patterns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription := r.subscription.clone()
+	for _, pattern := range patterns {
+		subscription.remove(pattern, subscribedChannel)
+	}
+	if err := r.setBPF(ctx, subscription); err != nil {
+		return fmt.Errorf("remove channels from LCM receiver: %w", err)
+	}
+	r.subscription = subscription
+	return nil
+}
+
+// AddProtos subscribes to the channels of additional proto messages, and registers the messages for ReceiveProto.
+func (r *Receiver) AddProtos(ctx context.Context, msgs ...proto.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription := r.subscription.clone()
+	for _, msg := range msgs {
+		subscription.add(string(msg.ProtoReflect().Descriptor().FullName()), subscribedProto)
+	}
+	if err := r.setBPF(ctx, subscription); err != nil {
+		return fmt.Errorf("add protos to LCM receiver: %w", err)
+	}
+	r.subscription = subscription
+	for _, msg := range msgs {
+		r.protoMessages[string(msg.ProtoReflect().Descriptor().FullName())] = proto.Clone(msg)
+	}
+	return nil
+}
+
+// RemoveProtos unsubscribes from the channels of proto messages, and unregisters the messages for ReceiveProto.
+//
+// Channels also subscribed to with WithReceiveChannels or AddChannels remain subscribed. When the last channel is
+// removed, the receiver receives no messages until channels are added again.
+func (r *Receiver) RemoveProtos(ctx context.Context, msgs ...proto.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription := r.subscription.clone()
+	for _, msg := range msgs {
+		subscription.remove(string(msg.ProtoReflect().Descriptor().FullName()), subscribedProto)
+	}
+	if err := r.setBPF(ctx, subscription); err != nil {
+		return fmt.Errorf("remove protos from LCM receiver: %w", err)
+	}
+	r.subscription = subscription
+	for _, msg := range msgs {
+		delete(r.protoMessages, string(msg.ProtoReflect().Descriptor().FullName()))
+	}
+	return nil
+}
+
+// isSubscribed reports if the channel is subscribed to.
+func (r *Receiver) isSubscribed(channel string) bool {
+	if r.opts.shards > 1 && int(lcmbpf.ChannelHash(channel)%uint32(r.opts.shards)) != r.opts.shard {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.subscription.matches(channel)
+}
+
+// setBPF assembles the BPF program for the subscription and sets it on the receiver socket.
+//
+// When the program can not be set, the receiver falls back to receiving all messages. The fallback is only reported
+// to the metrics when the receiver is configured to filter messages.
+func (r *Receiver) setBPF(ctx context.Context, subscription *channelSubscription) error {
+	if r.unixConn != nil || r.shm != nil {
+		return nil // the BPF programs expect UDP datagrams
+	}
+	bpfProgram, err := r.opts.receiverBPF(subscription)
+	if err != nil {
+		return fmt.Errorf("assembling bpf: %w", err)
+	}
+	if len(bpfProgram) > 0 && (runtime.GOOS != "linux" || len(bpfProgram) > lcmbpf.MaxInstructions) {
+		r.skipBPF(ctx, subscription, len(bpfProgram), slog.String("os", runtime.GOOS))
+		bpfProgram = nil
+	}
+	if err := r.setBPFProgram(ctx, bpfProgram); err != nil {
+		// the kernel also limits the size of socket filters by the net.core.optmem_max sysctl
+		if len(bpfProgram) == 0 || !errors.Is(err, syscall.ENOMEM) {
+			return err
+		}
+		r.skipBPF(ctx, subscription, len(bpfProgram), slog.Any("error", err))
+		return r.setBPFProgram(ctx, nil)
+	}
+	return nil
+}
+
+// skipBPF reports that the BPF program is skipped, and that the receiver falls back to receiving all messages.
+func (r *Receiver) skipBPF(ctx context.Context, subscription *channelSubscription, instructions int, attr slog.Attr) {
+	// skipping only the default filter is expected outside Linux, and doesn't change which messages are received
+	level := slog.LevelDebug
+	if r.opts.filtersChannels(subscription) {
+		r.opts.metrics.BPFFallback(instructions)
+		level = slog.LevelWarn
+	}
+	r.opts.logger.LogAttrs(
+		ctx,
+		level,
+		"skipped BPF program, falling back to receiving all messages",
+		slog.Int("instructions", instructions),
+		attr,
+	)
+}
+
+// setBPFProgram sets the BPF program on the receiver socket, where an empty program accepts all datagrams.
+func (r *Receiver) setBPFProgram(ctx context.Context, bpfProgram []bpf.Instruction) error {
+	if len(bpfProgram) == 0 {
+		if !r.bpfSet {
+			return nil
+		}
+		// replace the previously set program with one accepting all datagrams
+		bpfProgram = []bpf.Instruction{bpf.RetConstant{Val: 0xffff}}
+	}
+	rawBPFInstructions, err := bpf.Assemble(bpfProgram)
+	if err != nil {
+		return fmt.Errorf("assembling bpf: %w", err)
+	}
+	if err := r.conn.SetBPF(rawBPFInstructions); err != nil {
+		return fmt.Errorf("setting bpf: %w", err)
+	}
+	r.bpfSet = true
+	r.opts.logger.LogAttrs(ctx, slog.LevelDebug, "set BPF program", slog.Int("instructions", len(bpfProgram)))
+	return nil
+}
+
 // ProtoMessage returns the last received proto message.
 func (r *Receiver) ProtoMessage() proto.Message {
 	return r.protoMessage
 }
 
+// ReceiveOwned receives an LCM message, like Receive, and returns a copy of it owned by the caller.
+//
+// The returned message is drawn from a pool, and should be released by the caller when done with it.
+func (r *Receiver) ReceiveOwned(ctx context.Context) (*OwnedMessage, error) {
+	if err := r.Receive(ctx); err != nil {
+		return nil, err
+	}
+	return newOwnedMessage(&r.currMessage), nil
+}
+
 // Message returns the last received message.
+//
+// The message data is only valid until the next call to Receive, use Message.Clone or ReceiveOwned to keep it.
 func (r *Receiver) Message() *Message {
 	return &r.currMessage
 }
 
+// RawMessage returns the datagram of the last received message.
+//
+// The datagram is only valid until the next call to Receive.
+func (r *Receiver) RawMessage() []byte {
+	return r.rawMessage
+}
+
 // SourceAddress returns the source address of the last received message.
 func (r *Receiver) SourceAddress() net.IP {
 	return r.srcAddr
@@ -200,12 +625,40 @@ func (r *Receiver) InterfaceIndex() int {
 	return r.ifIndex
 }
 
+// Stats returns the receive statistics, with sequence numbers tracked per sender source address and port, or per
+// sender socket path for Unix datagram receivers.
+//
+// Kernel drop counters are only available on Linux.
+//
+// Safe to call concurrently with Receive.
+func (r *Receiver) Stats() ReceiverStats {
+	return r.stats.snapshot()
+}
+
 // Close the receiver connection after leaving all joined multicast groups.
+//
+// Closing a closed receiver returns the result of the first Close.
 func (r *Receiver) Close() error {
-	for _, ip := range r.opts.ips {
-		if err := r.conn.LeaveGroup(r.ifi, &net.UDPAddr{IP: ip}); err != nil {
-			return fmt.Errorf("close LCM receiver: %w", err)
-		}
+	r.closeOnce.Do(func() {
+		r.closeErr = r.close()
+	})
+	return r.closeErr
+}
+
+// close the receiver connection.
+func (r *Receiver) close() error {
+	if r.shm != nil {
+		return r.closeSharedMemory()
+	}
+	if r.unixConn != nil {
+		return r.closeUnixgram()
+	}
+	if r.closed != nil {
+		close(r.closed)
+		<-r.refreshDone
+	}
+	if err := r.leaveInterfaces(context.Background()); err != nil {
+		return fmt.Errorf("close LCM receiver: %w", errors.Join(err, r.conn.Close()))
 	}
 	return r.conn.Close()
 }
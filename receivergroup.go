@@ -0,0 +1,106 @@
+package lcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ListenMulticastUDPGroup returns a ReceiverGroup of receivers configured with the provided options, sharing the same
+// port with SO_REUSEPORT.
+//
+// The channels are sharded across the receivers by a hash of the channel, so that all messages on a channel are
+// received in order by the same receiver. The sharding is done by a BPF program on each receiver socket, and by a
+// reuse port socket selection program for datagrams sent directly to the port. When the BPF programs can not be set,
+// each receiver falls back to discarding the channels of the other receivers.
+//
+// The reuse port program selects a receiver by the position of its socket in the SO_REUSEPORT group of the port, so
+// the receivers must be the only sockets bound to the port. When other sockets share the port, such as other
+// receivers or groups in this or another process, datagrams sent directly to the port may be steered to a receiver
+// that discards them. Multicast datagrams are delivered to every socket, and are not affected.
+//
+// Only supported on Linux.
+func ListenMulticastUDPGroup(
+	ctx context.Context,
+	receivers int,
+	receiverOpts ...ReceiverOption,
+) (_ *ReceiverGroup, err error) {
+	if receivers < 1 {
+		return nil, fmt.Errorf("listen multicast UDP group: invalid number of receivers: %d", receivers)
+	}
+	g := &ReceiverGroup{}
+	defer func() {
+		if err != nil {
+			_ = g.Close()
+		}
+	}()
+	for i := 0; i < receivers; i++ {
+		rx, err := ListenMulticastUDP(ctx, append(slices.Clone(receiverOpts), withReceiveShard(receivers, i))...)
+		if err != nil {
+			return nil, fmt.Errorf("listen multicast UDP group: receiver %d: %w", i, err)
+		}
+		g.receivers = append(g.receivers, rx)
+	}
+	return g, nil
+}
+
+// ReceiverGroup is a group of LCM receivers sharing the same port, with the channels sharded across the receivers.
+type ReceiverGroup struct {
+	receivers []*Receiver
+}
+
+// Receivers returns the receivers of the group.
+func (g *ReceiverGroup) Receivers() []*Receiver {
+	return g.receivers
+}
+
+// Receive LCM messages on all receivers concurrently, with one goroutine per receiver.
+//
+// The handler is called with the receiver of each received message, from the goroutine of the receiver, until the
+// context is canceled or a receiver or handler returns an error.
+func (g *ReceiverGroup) Receive(ctx context.Context, handler func(context.Context, *Receiver) error) error {
+	return g.receive(ctx, (*Receiver).Receive, handler)
+}
+
+// ReceiveProto receives proto LCM messages on all receivers concurrently, with one goroutine per receiver.
+//
+// The handler is called with the receiver of each received message, from the goroutine of the receiver, until the
+// context is canceled or a receiver or handler returns an error.
+func (g *ReceiverGroup) ReceiveProto(ctx context.Context, handler func(context.Context, *Receiver) error) error {
+	return g.receive(ctx, (*Receiver).ReceiveProto, handler)
+}
+
+func (g *ReceiverGroup) receive(
+	ctx context.Context,
+	receive func(*Receiver, context.Context) error,
+	handler func(context.Context, *Receiver) error,
+) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, rx := range g.receivers {
+		eg.Go(func() error {
+			for {
+				if err := receive(rx, ctx); err != nil {
+					return err
+				}
+				if err := handler(ctx, rx); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	return eg.Wait()
+}
+
+// Close all receivers of the group.
+func (g *ReceiverGroup) Close() error {
+	var errs []error
+	for _, rx := range g.receivers {
+		if err := rx.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
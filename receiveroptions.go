@@ -1,21 +1,51 @@
 package lcm
 
 import (
+	"fmt"
+	"log/slog"
 	"net"
+	"os"
+	"slices"
+	"strconv"
+	"time"
 
+	"go.einride.tech/lcm/lcmbpf"
 	"golang.org/x/net/bpf"
 	"google.golang.org/protobuf/proto"
 )
 
 // receiverOptions are the configuration options for an LCM receiver.
 type receiverOptions struct {
-	interfaceName   string
+	interfaceNames  []string
+	allInterfaces   bool
+	refreshInterval time.Duration
+	refreshSet      bool
 	port            int
 	ips             []net.IP
+	sources         []sourceSpecificGroup
+	bindAddress     net.IP
+	// unicast is set for receivers of unicast and broadcast datagrams, which don't join multicast groups.
+	unicast         bool
 	bufferSizeBytes int
 	batchSize       int
 	bpfProgram      []bpf.Instruction
+	bpfProgramSet   bool
+	filters         []lcmbpf.Condition
 	protos          []proto.Message
+	// channelProtos are the proto messages received on the channels in protoChannels.
+	channelProtos []proto.Message
+	// protoChannels are the full names of the proto messages received on channels other than their full names.
+	protoChannels map[string]string
+	channels      []string
+	metrics       ReceiverMetrics
+	logger        *slog.Logger
+	skipMalformed bool
+	// socketMode is the file mode of the socket of a Unix datagram receiver.
+	socketMode os.FileMode
+	// shards is the number of receivers in a receiver group sharing the port with SO_REUSEPORT.
+	shards int
+	// shard is the index of the receiver in its receiver group.
+	shard int
 }
 
 // DefaultMulticastIP returns the default LCM multicast IP.
@@ -31,8 +61,9 @@ func defaultReceiverOptions() *receiverOptions {
 	return &receiverOptions{
 		batchSize:       5,
 		port:            DefaultPort,
-		bufferSizeBytes: 2097152,              // 2MB (from the LCM documentation)
-		bpfProgram:      shortMessageFilter(), // TODO: add support for fragmented messages
+		bufferSizeBytes: 2097152, // 2MB (from the LCM documentation)
+		metrics:         noopMetrics{},
+		logger:          slog.New(slog.DiscardHandler),
 	}
 }
 
@@ -46,10 +77,37 @@ func WithReceivePort(port int) ReceiverOption {
 	}
 }
 
-// WithReceiveInterface configures the interface to receive on.
+// WithReceiveInterface configures an interface to receive on.
+//
+// Provide this option multiple times to join the multicast groups on multiple interfaces.
 func WithReceiveInterface(interfaceName string) ReceiverOption {
 	return func(o *receiverOptions) {
-		o.interfaceName = interfaceName
+		if interfaceName != "" {
+			o.interfaceNames = append(o.interfaceNames, interfaceName)
+		}
+	}
+}
+
+// WithReceiveAllInterfaces configures the receiver to join the multicast groups on all up multicast interfaces.
+//
+// Interfaces that appear after the receiver has been created are joined periodically, see
+// WithReceiveInterfaceRefresh. When no interfaces are up, a warning is logged and the receiver waits for interfaces
+// to appear, or fails to be created if the refresh is disabled.
+func WithReceiveAllInterfaces() ReceiverOption {
+	return func(o *receiverOptions) {
+		o.allInterfaces = true
+	}
+}
+
+// WithReceiveInterfaceRefresh configures the interval for joining the multicast groups on interfaces that appear
+// after the receiver has been created.
+//
+// Defaults to 5 seconds when receiving on all interfaces, and to no refresh otherwise. Provide a zero interval to
+// disable the refresh.
+func WithReceiveInterfaceRefresh(interval time.Duration) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.refreshInterval = interval
+		o.refreshSet = true
 	}
 }
 
@@ -62,22 +120,88 @@ func WithReceiveAddress(ip net.IP) ReceiverOption {
 	}
 }
 
+// WithReceiveSource configures a source-specific multicast group to receive from, only accepting traffic to the
+// group from the source address.
+//
+// Provide this option multiple times to join multiple source-specific groups, or the same group from multiple sources.
+// When only source-specific groups are configured, the default multicast group is not joined.
+func WithReceiveSource(group, source net.IP) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.sources = append(o.sources, sourceSpecificGroup{group: group, source: source})
+	}
+}
+
+// sourceSpecificGroup is a source-specific multicast group.
+type sourceSpecificGroup struct {
+	group  net.IP
+	source net.IP
+}
+
+// WithReceiveBindAddress configures the address to bind the receiver socket to.
+//
+// Bind to the unspecified address (0.0.0.0) to receive on all local addresses, to a multicast group to only receive
+// datagrams to the group, or to a unicast address to only receive datagrams to the address. No multicast groups are
+// joined when binding to a unicast address, and the bound multicast group is joined by default.
+//
+// Defaults to receiving datagrams to any address, with the socket configured for sharing the port with other
+// multicast receivers.
+func WithReceiveBindAddress(ip net.IP) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.bindAddress = ip
+	}
+}
+
 // WithReceiveBPF configures the Berkely Packet Filter to set on the receiver socket.
 //
+// The program replaces the default filter accepting only LCM short messages, and is combined with the channels of
+// any configured protos and filter conditions. Provide a nil program to disable the default filter.
+//
 // Ineffectual in non-Linux environments.
 func WithReceiveBPF(program []bpf.Instruction) ReceiverOption {
 	return func(o *receiverOptions) {
 		o.bpfProgram = program
+		o.bpfProgramSet = true
+	}
+}
+
+// WithReceiveFilter configures filter conditions to combine into the Berkeley Packet Filter on the receiver socket.
+//
+// Provide this option multiple times to require all conditions to hold.
+//
+// Ineffectual in non-Linux environments.
+func WithReceiveFilter(conditions ...lcmbpf.Condition) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.filters = append(o.filters, conditions...)
 	}
 }
 
+// WithReceiveProtos configures the proto messages to receive.
+//
+// The receiver only accepts messages on channels equal to the fully-qualified names of the messages, or on channels
+// configured with WithReceiveChannels. Messages transmitted on other channels with a "t" type param, such as
+// "camera.front?t=example.Image", are filtered by their channel and not by their type, so their channels must be
+// subscribed to explicitly, such as with WithReceiveChannels("camera.*").
 func WithReceiveProtos(msgs ...proto.Message) ReceiverOption {
 	return func(o *receiverOptions) {
-		o.bpfProgram = shortProtoMessageFilter(msgs...)
 		o.protos = msgs
 	}
 }
 
+// WithReceiveProtoOnChannel configures a proto message to receive with ReceiveProto on a channel other than its
+// fully-qualified name, such as the messages transmitted with TransmitProtoOnChannel.
+//
+// Provide this option multiple times to receive proto messages on multiple channels.
+func WithReceiveProtoOnChannel(channel string, msg proto.Message) ReceiverOption {
+	return func(o *receiverOptions) {
+		if o.protoChannels == nil {
+			o.protoChannels = make(map[string]string)
+		}
+		o.protoChannels[channel] = string(msg.ProtoReflect().Descriptor().FullName())
+		o.channelProtos = append(o.channelProtos, msg)
+		o.channels = append(o.channels, channel)
+	}
+}
+
 // WithReceiveBufferSize configures the kernel read buffer size (in bytes).
 func WithReceiveBufferSize(n int) ReceiverOption {
 	return func(o *receiverOptions) {
@@ -91,3 +215,119 @@ func WithReceiveBatchSize(n int) ReceiverOption {
 		o.batchSize = n
 	}
 }
+
+// WithReceiveMetrics configures the metrics to report receiver instrumentation to.
+//
+// A nil metrics disables instrumentation.
+func WithReceiveMetrics(metrics ReceiverMetrics) ReceiverOption {
+	return func(o *receiverOptions) {
+		if metrics == nil {
+			metrics = noopMetrics{}
+		}
+		o.metrics = metrics
+	}
+}
+
+// WithReceiveLogger configures the logger to emit structured events for receiver setup, fallbacks and errors to.
+//
+// A nil logger discards all events.
+func WithReceiveLogger(logger *slog.Logger) ReceiverOption {
+	return func(o *receiverOptions) {
+		if logger == nil {
+			logger = slog.New(slog.DiscardHandler)
+		}
+		o.logger = logger
+	}
+}
+
+// WithReceiveSkipMalformed configures the receiver to skip malformed messages instead of returning an error.
+//
+// Skipped messages are counted in the receiver stats.
+func WithReceiveSkipMalformed(b bool) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.skipMalformed = b
+	}
+}
+
+// WithReceiveSocketMode configures the file mode of the socket of a Unix datagram receiver.
+//
+// Transmitters need write permission on the socket to transmit to the receiver. The socket is bound in a private
+// directory and moved into the socket directory once its mode is set, so it is never reachable with the mode given by
+// the umask.
+func WithReceiveSocketMode(mode os.FileMode) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.socketMode = mode
+	}
+}
+
+// WithReceiveChannels configures channel patterns to receive, where '*' matches any sequence of characters.
+//
+// Patterns without wildcards or with a single trailing wildcard, such as "vehicle.sensors.*", are filtered in the
+// kernel. Other patterns, such as "vehicle.*.state", are filtered in the kernel by the prefix before the first wildcard
+// and matched in userspace.
+//
+// Provide this option multiple times to receive multiple channel patterns.
+func WithReceiveChannels(patterns ...string) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.channels = append(o.channels, patterns...)
+	}
+}
+
+// registeredProtos returns the proto messages to register for ReceiveProto.
+func (o *receiverOptions) registeredProtos() []proto.Message {
+	return append(slices.Clone(o.protos), o.channelProtos...)
+}
+
+// listenAddress returns the address to bind the receiver socket to.
+//
+// Multicast addresses are bound as the unspecified address, with SO_REUSEADDR set on the socket. The unspecified
+// address of multicast receivers is bound the same way, so that multiple receivers can share the port.
+func (o *receiverOptions) listenAddress() string {
+	if o.bindAddress == nil || (o.bindAddress.IsUnspecified() && !o.unicast) {
+		// wildcard address prefix for all administratively-scoped (local) multicast addresses
+		return fmt.Sprintf("239.0.0.0:%d", o.port)
+	}
+	return net.JoinHostPort(o.bindAddress.String(), strconv.Itoa(o.port))
+}
+
+// joinsMulticastGroups reports if the receiver joins multicast groups, which it does unless bound to a unicast address
+// or receiving unicast datagrams.
+func (o *receiverOptions) joinsMulticastGroups() bool {
+	if o.unicast {
+		return false
+	}
+	return o.bindAddress == nil || o.bindAddress.IsUnspecified() || o.bindAddress.IsMulticast()
+}
+
+// interfaceRefreshInterval returns the interval for joining the multicast groups on new interfaces, or zero for no
+// refresh.
+func (o *receiverOptions) interfaceRefreshInterval() time.Duration {
+	switch {
+	case o.refreshSet:
+		return o.refreshInterval
+	case o.allInterfaces:
+		return 5 * time.Second
+	default:
+		return 0
+	}
+}
+
+// withReceiveShard configures the receiver as one of the shards of a receiver group, receiving the channels with a
+// channel hash matching the shard.
+func withReceiveShard(shards, shard int) ReceiverOption {
+	return func(o *receiverOptions) {
+		o.shards = shards
+		o.shard = shard
+	}
+}
+
+// withReceiveUnicast configures the receiver to receive unicast and broadcast datagrams, bound to the unspecified
+// address unless another bind address is configured.
+func withReceiveUnicast() ReceiverOption {
+	return func(o *receiverOptions) {
+		o.unicast = true
+		if o.bindAddress == nil {
+			o.bindAddress = net.IPv4zero
+		}
+	}
+}
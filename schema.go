@@ -0,0 +1,261 @@
+package lcm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// SchemaChannel is the channel of proto schemas, published by transmitters configured with WithTransmitSchemas.
+//
+// Each message is a google.protobuf.FileDescriptorSet with the file of a proto message type and its transitive
+// dependencies, dependencies first.
+const SchemaChannel = "lcm.schema"
+
+// SchemaRequestChannel is the channel of requests for proto schemas, answered by Transmitter.ServeSchemaRequests.
+//
+// Each message is a newline-separated list of full names of the requested proto message types, or empty to request
+// all schemas.
+const SchemaRequestChannel = "lcm.schema.request"
+
+// lengthOfLargestSchema is the length in bytes of the largest schema that fits in a datagram on SchemaChannel.
+const lengthOfLargestSchema = lengthOfLargestPayload - len(SchemaChannel) - 1
+
+// schemaSet is the set of proto schemas of the messages transmitted by a transmitter.
+//
+// Thread-safe.
+type schemaSet struct {
+	mu      sync.Mutex
+	schemas map[protoreflect.FullName][]byte
+	names   []protoreflect.FullName
+	// oversized are the proto message types with schemas too large to publish.
+	oversized map[protoreflect.FullName]bool
+}
+
+// marshal the schema of a proto message type that has not been added, and report if it should be published.
+//
+// A schema too large to publish results in an error, and is not marshaled again.
+func (s *schemaSet) marshal(md protoreflect.MessageDescriptor) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schemas[md.FullName()]; ok || s.oversized[md.FullName()] {
+		return nil, false, nil
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: appendFileClosure(nil, make(map[string]bool), md.ParentFile()),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal schema of %s: %w", md.FullName(), err)
+	}
+	if len(data) > lengthOfLargestSchema {
+		if s.oversized == nil {
+			s.oversized = make(map[protoreflect.FullName]bool)
+		}
+		s.oversized[md.FullName()] = true
+		return nil, false, fmt.Errorf(
+			"schema of %s too large to publish: %d bytes, max %d bytes", md.FullName(), len(data), lengthOfLargestSchema,
+		)
+	}
+	return data, true, nil
+}
+
+// add the published schema of a proto message type.
+func (s *schemaSet) add(name protoreflect.FullName, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.schemas[name]; ok {
+		return
+	}
+	if s.schemas == nil {
+		s.schemas = make(map[protoreflect.FullName][]byte)
+	}
+	s.schemas[name] = data
+	s.names = append(s.names, name)
+}
+
+// get the schemas of the proto message types with the provided names, or all schemas if no names are provided.
+func (s *schemaSet) get(names ...protoreflect.FullName) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(names) == 0 {
+		names = s.names
+	}
+	schemas := make([][]byte, 0, len(names))
+	for _, name := range names {
+		if schema, ok := s.schemas[name]; ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+// appendFileClosure appends the file and its transitive dependencies not yet seen, dependencies first.
+func appendFileClosure(
+	files []*descriptorpb.FileDescriptorProto, seen map[string]bool, fd protoreflect.FileDescriptor,
+) []*descriptorpb.FileDescriptorProto {
+	if seen[fd.Path()] {
+		return files
+	}
+	seen[fd.Path()] = true
+	imports := fd.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		files = appendFileClosure(files, seen, imports.Get(i).FileDescriptor)
+	}
+	return append(files, protodesc.ToFileDescriptorProto(fd))
+}
+
+// observeSchema publishes the schema of a transmitted proto message type if it is new, and adds it once published.
+//
+// A schema that fails to publish is published again when the proto message type is transmitted again.
+//
+// Must be called with the transmitter lock held.
+func (t *Transmitter) observeSchema(ctx context.Context, md protoreflect.MessageDescriptor) error {
+	data, ok, err := t.schemas.marshal(md)
+	if err != nil || !ok {
+		return err
+	}
+	if err := t.transmit(ctx, SchemaChannel, "", data); err != nil {
+		return err
+	}
+	t.schemas.add(md.FullName(), data)
+	return nil
+}
+
+// publishSchemas publishes the schemas of the proto message types with the provided names, or all schemas if no
+// names are provided.
+func (t *Transmitter) publishSchemas(ctx context.Context, names ...protoreflect.FullName) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, schema := range t.schemas.get(names...) {
+		if err := t.transmit(ctx, SchemaChannel, "", schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeSchemaRequests answers the schema requests received by the provided receiver, until the provided context is
+// done or receiving fails.
+//
+// The receiver is subscribed to SchemaRequestChannel, and should be dedicated to schema requests. Only the schemas of
+// proto message types transmitted by a transmitter configured with WithTransmitSchemas are known.
+func (t *Transmitter) ServeSchemaRequests(ctx context.Context, rx *Receiver) error {
+	if err := rx.AddChannels(ctx, SchemaRequestChannel); err != nil {
+		return fmt.Errorf("serve schema requests: %w", err)
+	}
+	for {
+		if err := rx.Receive(ctx); err != nil {
+			return fmt.Errorf("serve schema requests: %w", err)
+		}
+		if rx.Message().Channel != SchemaRequestChannel {
+			continue
+		}
+		var names []protoreflect.FullName
+		for name := range strings.SplitSeq(string(rx.Message().Data), "\n") {
+			if name != "" {
+				names = append(names, protoreflect.FullName(name))
+			}
+		}
+		if err := t.publishSchemas(ctx, names...); err != nil {
+			return fmt.Errorf("serve schema requests: %w", err)
+		}
+	}
+}
+
+// RequestSchemas requests the schemas of the proto message types with the provided full names, or all schemas if no
+// names are provided, from the transmitters serving schema requests.
+func (t *Transmitter) RequestSchemas(ctx context.Context, names ...protoreflect.FullName) error {
+	var request strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			request.WriteByte('\n')
+		}
+		request.WriteString(string(name))
+	}
+	return t.Transmit(ctx, SchemaRequestChannel, []byte(request.String()))
+}
+
+// SchemaRegistry collects the proto schemas published on SchemaChannel, for decoding messages of proto types that are
+// not compiled into the program.
+//
+// When transmitters publish different versions of a file, the first received version is kept.
+//
+// Safe for concurrent use.
+type SchemaRegistry struct {
+	mu    sync.Mutex
+	files *protoregistry.Files
+}
+
+// NewSchemaRegistry returns a new empty schema registry.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{files: new(protoregistry.Files)}
+}
+
+// Observe collects the schemas of a received message, and reports if it was a message on SchemaChannel.
+func (s *SchemaRegistry) Observe(m *Message) (bool, error) {
+	if m.Channel != SchemaChannel {
+		return false, nil
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(m.Data, &set); err != nil {
+		return true, fmt.Errorf("observe schema: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, file := range set.GetFile() {
+		if _, err := s.files.FindFileByPath(file.GetName()); err == nil {
+			continue
+		}
+		fd, err := protodesc.NewFile(file, s.files)
+		if err != nil {
+			return true, fmt.Errorf("observe schema %s: %w", file.GetName(), err)
+		}
+		if err := s.files.RegisterFile(fd); err != nil {
+			return true, fmt.Errorf("observe schema %s: %w", file.GetName(), err)
+		}
+	}
+	return true, nil
+}
+
+// FindMessageByName returns the descriptor of the proto message type with the provided full name.
+func (s *SchemaRegistry) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageDescriptor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message: %w", name, protoregistry.NotFound)
+	}
+	return md, nil
+}
+
+// Decode a received message into a dynamic proto message.
+//
+// The proto message type is given by the "t" channel param of the message, or else the channel is assumed to be a
+// fully-qualified message name. Messages of unknown types result in an error wrapping protoregistry.NotFound.
+func (s *SchemaRegistry) Decode(m *Message) (proto.Message, error) {
+	name := m.Param(paramType)
+	if name == "" {
+		name = m.Channel
+	}
+	md, err := s.FindMessageByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", m.Channel, err)
+	}
+	dm := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(m.Data, dm); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", m.Channel, err)
+	}
+	return dm, nil
+}
@@ -0,0 +1,90 @@
+package lcm
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gotest.tools/v3/assert"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	var schemas schemaSet
+	md := (&timestamppb.Timestamp{}).ProtoReflect().Descriptor()
+	schema, ok, err := schemas.marshal(md)
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	// a schema is only added once published
+	_, ok, err = schemas.marshal(md)
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+	schemas.add(md.FullName(), schema)
+	_, ok, err = schemas.marshal(md)
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+	registry := NewSchemaRegistry()
+	data, err := proto.Marshal(&timestamppb.Timestamp{Seconds: 1, Nanos: 2})
+	assert.NilError(t, err)
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := registry.Decode(&Message{Channel: "google.protobuf.Timestamp", Data: data})
+		assert.ErrorIs(t, err, protoregistry.NotFound)
+	})
+	t.Run("not a schema", func(t *testing.T) {
+		ok, err := registry.Observe(&Message{Channel: "google.protobuf.Timestamp", Data: data})
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	})
+	t.Run("observe", func(t *testing.T) {
+		for range 2 {
+			ok, err := registry.Observe(&Message{Channel: SchemaChannel, Data: schemas.get()[0]})
+			assert.NilError(t, err)
+			assert.Assert(t, ok)
+		}
+	})
+	t.Run("decode channel", func(t *testing.T) {
+		m, err := registry.Decode(&Message{Channel: "google.protobuf.Timestamp", Data: data})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, m, protocmp.Transform())
+	})
+	t.Run("decode type param", func(t *testing.T) {
+		m, err := registry.Decode(&Message{Channel: "foo", Params: "t=google.protobuf.Timestamp", Data: data})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, &timestamppb.Timestamp{Seconds: 1, Nanos: 2}, m, protocmp.Transform())
+	})
+	t.Run("get", func(t *testing.T) {
+		assert.Equal(t, 0, len(schemas.get((&durationpb.Duration{}).ProtoReflect().Descriptor().FullName())))
+	})
+}
+
+func TestSchemaSet_Oversized(t *testing.T) {
+	// a message type with a schema larger than a datagram
+	message := &descriptorpb.DescriptorProto{Name: proto.String("Large")}
+	for i := range 2000 {
+		message.Field = append(message.Field, &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(fmt.Sprintf("field_with_a_long_descriptive_name_%d", i)),
+			Number: proto.Int32(int32(i + 1)),
+			Type:   descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		})
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("large.proto"),
+		Package:     proto.String("example"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{message},
+	}, nil)
+	assert.NilError(t, err)
+	var schemas schemaSet
+	_, _, err = schemas.marshal(fd.Messages().Get(0))
+	assert.ErrorContains(t, err, "schema of example.Large too large to publish")
+	// and the oversized schema should only be reported once
+	_, ok, err := schemas.marshal(fd.Messages().Get(0))
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+}
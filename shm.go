@@ -0,0 +1,446 @@
+package lcm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"go.einride.tech/lcm/compression/lcmlz4"
+	"golang.org/x/net/ipv4"
+	"google.golang.org/protobuf/proto"
+)
+
+// shared-memory ring buffer header layout.
+//
+// All positions are byte offsets from the start of the ring buffer data, increasing monotonically. The position in the
+// data area is the offset modulo the capacity.
+const (
+	// offsetShmMagic is the offset of the ring buffer magic, written last when initializing the ring buffer.
+	offsetShmMagic = 0
+	// offsetShmVersion is the offset of the ring buffer format version.
+	offsetShmVersion = 4
+	// offsetShmCapacity is the offset of the capacity of the data area in bytes.
+	offsetShmCapacity = 8
+	// offsetShmReserved is the offset of the end position of the record being written.
+	offsetShmReserved = 16
+	// offsetShmHead is the offset of the end position of the last written record.
+	offsetShmHead = 24
+	// offsetShmNotify is the offset of the futex word, incremented for each written record.
+	offsetShmNotify = 32
+	// offsetShmWaiters is the offset of the number of receivers waiting on the futex word.
+	offsetShmWaiters = 36
+	// offsetShmLast is the offset of the start position of the last written record.
+	offsetShmLast = 40
+	// offsetShmRecords is the offset of the number of written records, which is the index of the next record.
+	offsetShmRecords = 48
+	// lengthOfShmHeader is the length in bytes of the ring buffer header preceding the data area.
+	lengthOfShmHeader = 64
+)
+
+// shared-memory record header layout.
+//
+// Each record is a header followed by an LCM datagram, padded to a multiple of shmRecordAlignment bytes.
+const (
+	// offsetShmRecordLength is the offset of the padded length of the record, including the header.
+	offsetShmRecordLength = 0
+	// offsetShmRecordDatagramLength is the offset of the length of the datagram.
+	offsetShmRecordDatagramLength = 4
+	// offsetShmRecordIndex is the offset of the index of the record, counting all records written to the ring buffer.
+	offsetShmRecordIndex = 8
+	// lengthOfShmRecordHeader is the length in bytes of a record header.
+	lengthOfShmRecordHeader = 16
+	// shmRecordAlignment is the alignment in bytes of records.
+	shmRecordAlignment = 8
+	// shmPaddingFlag is set in the record length of padding records, which skip to the start of the data area.
+	shmPaddingFlag = 1 << 31
+)
+
+// shared-memory ring buffer constants.
+const (
+	// shmMagic is the uint32 magic number of an initialized ring buffer.
+	shmMagic = 0x4c434d52
+	// shmVersion is the ring buffer format version.
+	shmVersion = 2
+)
+
+// ListenSharedMemory returns a Receiver of the messages transmitted into the shared-memory ring buffer file at the
+// provided path, such as "/dev/shm/lcm-camera", configured with the provided options.
+//
+// The ring buffer must have been created by DialSharedMemory. Only messages transmitted after the receiver has been
+// created are received. Receivers that fall behind by more than the size of the ring buffer skip the overwritten
+// messages, which are counted as discarded in the receiver stats. The multicast options, such as the address, port and
+// interfaces, are ignored. BPF filtering is not applied, and messages on channels not subscribed to are discarded
+// after being read.
+//
+// Only supported on Linux.
+func ListenSharedMemory(ctx context.Context, path string, receiverOpts ...ReceiverOption) (*Receiver, error) {
+	opts := defaultReceiverOptions()
+	for _, receiverOpt := range receiverOpts {
+		receiverOpt(opts)
+	}
+	ring, err := openShmRing(path)
+	if err != nil {
+		return nil, fmt.Errorf("listen shared memory: %w", err)
+	}
+	rx := &Receiver{
+		opts:          opts,
+		shm:           &shmReader{ring: ring, tail: ring.head().Load()},
+		subscription:  newChannelSubscription(opts.channels, opts.protos),
+		interfaces:    make(map[int]*net.Interface),
+		protoMessages: make(map[string]proto.Message),
+		decompressors: map[string]Decompressor{"z=lz4": lcmlz4.NewDecompressor()},
+		messageBuf:    []ipv4.Message{{Buffers: [][]byte{make([]byte, lengthOfLargestUDPMessage)}}},
+	}
+	for _, msg := range opts.registeredProtos() {
+		rx.protoMessages[string(msg.ProtoReflect().Descriptor().FullName())] = proto.Clone(msg)
+	}
+	opts.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"listening for LCM messages",
+		slog.String("path", path),
+		slog.Uint64("capacity", ring.capacity),
+	)
+	return rx, nil
+}
+
+// readSharedMemory reads the next record of the ring buffer into the message buffer, waiting for the transmitter until
+// the context is done.
+func (r *Receiver) readSharedMemory(ctx context.Context) (int, error) {
+	for {
+		datagram, ok, err := r.shm.read(r.messageBuf[0].Buffers[0][:0])
+		if err != nil {
+			// the receiver has skipped past the invalid record, and counts the skipped records as discarded
+			r.opts.logger.LogAttrs(ctx, slog.LevelWarn, "skipped invalid shared memory record", slog.Any("error", err))
+			continue
+		}
+		if ok {
+			r.messageBuf[0].Buffers[0] = datagram
+			r.messageBuf[0].N = len(datagram)
+			r.messageBuf[0].NN = 0
+			r.messageBuf[0].Addr = nil
+			return 1, nil
+		}
+		if err := r.shm.wait(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// closeSharedMemory unmaps the ring buffer of the receiver.
+func (r *Receiver) closeSharedMemory() error {
+	if err := r.shm.ring.close(); err != nil {
+		return fmt.Errorf("close LCM receiver: %w", err)
+	}
+	return nil
+}
+
+// shmReader reads the records of a shared-memory ring buffer.
+type shmReader struct {
+	ring *shmRing
+	// tail is the position of the next record to read.
+	tail uint64
+	// next is the index of the next expected record, once a record has been read.
+	next     uint64
+	received bool
+	// skipped is the number of records skipped before the last read record.
+	skipped uint64
+}
+
+// read the next record into the buffer, and return the datagram of the record, or false if there are no new records.
+//
+// An invalid record, such as one left behind by a crashed transmitter, skips to the last written record, or to the head
+// of the ring buffer if the invalid record is the last written record, and is returned as an error. The skipped records
+// are counted from the index of the next read record.
+func (s *shmReader) read(buf []byte) ([]byte, bool, error) {
+	ring := s.ring
+	for s.tail != ring.head().Load() {
+		if ring.overwritten(s.tail) {
+			s.tail = ring.last().Load() // skip to the last written record
+			continue
+		}
+		position := s.tail % ring.capacity
+		remaining := ring.capacity - position
+		if remaining < lengthOfShmRecordHeader {
+			s.tail += remaining
+			continue
+		}
+		record := ring.data[position:]
+		length := uint64(binary.NativeEndian.Uint32(record[offsetShmRecordLength:]))
+		if length&shmPaddingFlag != 0 {
+			s.tail += remaining // padding records span to the end of the data area
+			continue
+		}
+		datagramLength := uint64(binary.NativeEndian.Uint32(record[offsetShmRecordDatagramLength:]))
+		index := binary.NativeEndian.Uint64(record[offsetShmRecordIndex:])
+		if length > remaining || length%shmRecordAlignment != 0 || lengthOfShmRecordHeader+datagramLength > length {
+			ring.fence()
+			if ring.overwritten(s.tail) {
+				continue
+			}
+			invalid := s.tail
+			if last := ring.last().Load(); last > s.tail {
+				s.tail = last
+			} else {
+				s.tail = ring.head().Load()
+			}
+			return buf, false, fmt.Errorf("invalid record at position %d", invalid)
+		}
+		buf = append(buf[:0], record[lengthOfShmRecordHeader:][:datagramLength]...)
+		ring.fence()
+		if ring.overwritten(s.tail) {
+			continue // the record was overwritten while being copied
+		}
+		s.tail += length
+		s.skipped = 0
+		// a record index before the next expected one is a recreated ring buffer
+		if diff := int64(index - s.next); s.received && diff > 0 {
+			s.skipped = uint64(diff)
+		}
+		s.next, s.received = index+1, true
+		return buf, true, nil
+	}
+	return buf, false, nil
+}
+
+// wait for the transmitter to write a record, until the context is done.
+func (s *shmReader) wait(ctx context.Context) error {
+	ring := s.ring
+	// wake the futex when the context is done, by bumping the futex word
+	woken := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		ring.wake()
+		close(woken)
+	})
+	defer func() {
+		if !stop() {
+			<-woken // don't let the wakeup outlive the wait
+		}
+	}()
+	ring.waiters().Add(1)
+	defer ring.waiters().Add(^uint32(0))
+	for {
+		value := ring.notify().Load()
+		if s.tail != ring.head().Load() {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var timeout time.Duration
+		if deadline, ok := ctx.Deadline(); ok {
+			if timeout = time.Until(deadline); timeout <= 0 {
+				return context.DeadlineExceeded
+			}
+		}
+		ring.wait(value, timeout)
+	}
+}
+
+// DialSharedMemory returns a Transmitter into the shared-memory ring buffer file at the provided path, such as
+// "/dev/shm/lcm-camera", configured with the provided options.
+//
+// The ring buffer file is created with the size configured with WithTransmitSharedMemorySize if it does not exist,
+// and is left in place when the transmitter is closed, so that receivers can keep using it. Each ring buffer has a
+// single transmitter. Messages are copied into the ring buffer without blocking on slow receivers, and may be up to
+// half the size of the ring buffer, beyond the size limit of UDP datagrams. The multicast options, such as the
+// interfaces, addresses and the TTL, are ignored.
+//
+// Only supported on Linux.
+func DialSharedMemory(ctx context.Context, path string, transmitterOpts ...TransmitterOption) (*Transmitter, error) {
+	opts := defaultTransmitterOptions()
+	for _, transmitterOpt := range transmitterOpts {
+		transmitterOpt(opts)
+	}
+	if opts.sharedMemorySize < 2*(lengthOfShmRecordHeader+indexOfChannel+lengthOfLongestChannel+1) {
+		return nil, fmt.Errorf("dial shared memory: size too small: %d bytes", opts.sharedMemorySize)
+	}
+	ring, err := createShmRing(path, uint64(opts.sharedMemorySize)&^(shmRecordAlignment-1))
+	if err != nil {
+		return nil, fmt.Errorf("dial shared memory: %w", err)
+	}
+	tx := &Transmitter{opts: opts, shm: ring}
+	opts.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"transmitting LCM messages",
+		slog.String("path", path),
+		slog.Uint64("capacity", ring.capacity),
+	)
+	tx.startBackground()
+	return tx, nil
+}
+
+// transmitSharedMemory marshals a message directly into the ring buffer, and returns the size of the datagram.
+func (t *Transmitter) transmitSharedMemory(m *Message) (int, error) {
+	rawChannel, err := m.rawChannel()
+	if err != nil {
+		return 0, fmt.Errorf("transmit to LCM: %w", err)
+	}
+	b, err := t.shm.reserve(m.marshaledLength(rawChannel))
+	if err != nil {
+		return 0, fmt.Errorf("transmit to LCM: %w", err)
+	}
+	n := m.put(b, rawChannel)
+	t.shm.commit()
+	return n, nil
+}
+
+// writeSharedMemory copies a datagram into the ring buffer.
+func (t *Transmitter) writeSharedMemory(datagram []byte) error {
+	b, err := t.shm.reserve(len(datagram))
+	if err != nil {
+		return fmt.Errorf("transmit to LCM: %w", err)
+	}
+	copy(b, datagram)
+	t.shm.commit()
+	return nil
+}
+
+// shmRing is a memory-mapped shared-memory ring buffer.
+//
+// Receivers copy records out of the ring buffer while the transmitter may be overwriting them, and detect overwritten
+// records from the reserved position, like a sequence lock.
+type shmRing struct {
+	file     *os.File
+	mem      []byte
+	data     []byte
+	capacity uint64
+	// fenceWord is the process-local word of fence.
+	fenceWord atomic.Uint64
+	// pending is the start position of the record reserved by the transmitter.
+	pending uint64
+	// pendingLength is the length of the record reserved by the transmitter.
+	pendingLength uint64
+}
+
+// validate the header of a mapped ring buffer.
+func (r *shmRing) validate() error {
+	if magic := r.magic().Load(); magic != shmMagic {
+		return fmt.Errorf("ring buffer %s is not initialized: magic 0x%x", r.file.Name(), magic)
+	}
+	if version := binary.NativeEndian.Uint32(r.mem[offsetShmVersion:]); version != shmVersion {
+		return fmt.Errorf("ring buffer %s has unsupported version %d", r.file.Name(), version)
+	}
+	capacity := binary.NativeEndian.Uint64(r.mem[offsetShmCapacity:])
+	if capacity%shmRecordAlignment != 0 || capacity > uint64(len(r.mem)-lengthOfShmHeader) {
+		return fmt.Errorf("ring buffer %s has invalid capacity %d", r.file.Name(), capacity)
+	}
+	r.capacity = capacity
+	r.data = r.mem[lengthOfShmHeader : lengthOfShmHeader+capacity]
+	return nil
+}
+
+// initialize the header of a new ring buffer with the provided capacity.
+//
+// The mapping must fit the header and the capacity.
+func (r *shmRing) initialize(capacity uint64) {
+	binary.NativeEndian.PutUint32(r.mem[offsetShmVersion:], shmVersion)
+	binary.NativeEndian.PutUint64(r.mem[offsetShmCapacity:], capacity)
+	r.capacity = capacity
+	r.data = r.mem[lengthOfShmHeader : lengthOfShmHeader+capacity]
+	r.magic().Store(shmMagic)
+}
+
+func (r *shmRing) magic() *atomic.Uint32 {
+	return (*atomic.Uint32)(unsafe.Pointer(&r.mem[offsetShmMagic]))
+}
+
+func (r *shmRing) reserved() *atomic.Uint64 {
+	return (*atomic.Uint64)(unsafe.Pointer(&r.mem[offsetShmReserved]))
+}
+
+func (r *shmRing) head() *atomic.Uint64 {
+	return (*atomic.Uint64)(unsafe.Pointer(&r.mem[offsetShmHead]))
+}
+
+func (r *shmRing) last() *atomic.Uint64 {
+	return (*atomic.Uint64)(unsafe.Pointer(&r.mem[offsetShmLast]))
+}
+
+func (r *shmRing) records() *atomic.Uint64 {
+	return (*atomic.Uint64)(unsafe.Pointer(&r.mem[offsetShmRecords]))
+}
+
+func (r *shmRing) notify() *atomic.Uint32 {
+	return (*atomic.Uint32)(unsafe.Pointer(&r.mem[offsetShmNotify]))
+}
+
+func (r *shmRing) waiters() *atomic.Uint32 {
+	return (*atomic.Uint32)(unsafe.Pointer(&r.mem[offsetShmWaiters]))
+}
+
+// fence orders the plain memory accesses of records before it with the atomic accesses of ring buffer positions after
+// it, and the other way around.
+//
+// Go has no explicit memory fence. An atomic read-modify-write operation is a full barrier on amd64, and on arm64 it
+// is an acquire and a release, which are ordered with the sequentially consistent loads and stores of the positions.
+// Without the fence, a receiver on a weakly ordered CPU could copy record data written after the reservation it
+// checks, or the transmitter could overwrite a record before the reservation is visible.
+func (r *shmRing) fence() {
+	r.fenceWord.Add(1)
+}
+
+// overwritten reports if the record at the position may have been overwritten by the transmitter.
+func (r *shmRing) overwritten(position uint64) bool {
+	return r.reserved().Load() > position+r.capacity
+}
+
+// reserve a record for a datagram of n bytes, and return the datagram area of the record to be committed.
+//
+// The reservation is published before the record is written, so that receivers copying overwritten records can
+// detect them.
+func (r *shmRing) reserve(n int) ([]byte, error) {
+	length := alignShmRecord(uint64(lengthOfShmRecordHeader + n))
+	if length > r.capacity/2 {
+		return nil, fmt.Errorf("datagram too long for ring buffer: %v bytes", n)
+	}
+	head := r.head().Load()
+	position := head % r.capacity
+	if remaining := r.capacity - position; remaining < length {
+		// skip to the start of the data area
+		r.reserved().Store(head + remaining + length)
+		r.fence()
+		if remaining >= lengthOfShmRecordHeader {
+			binary.NativeEndian.PutUint32(r.data[position+offsetShmRecordLength:], uint32(remaining)|shmPaddingFlag)
+		}
+		head += remaining
+		position = 0
+	} else {
+		r.reserved().Store(head + length)
+		r.fence()
+	}
+	record := r.data[position : position+length]
+	binary.NativeEndian.PutUint32(record[offsetShmRecordLength:], uint32(length))
+	binary.NativeEndian.PutUint32(record[offsetShmRecordDatagramLength:], uint32(n))
+	binary.NativeEndian.PutUint64(record[offsetShmRecordIndex:], r.records().Load())
+	r.pending, r.pendingLength = head, length
+	return record[lengthOfShmRecordHeader:][:n], nil
+}
+
+// commit the reserved record, and wake the waiting receivers.
+func (r *shmRing) commit() {
+	r.records().Add(1)
+	r.last().Store(r.pending)
+	r.head().Store(r.pending + r.pendingLength)
+	r.wake()
+}
+
+// closeSharedMemory unmaps the ring buffer of the transmitter.
+func (t *Transmitter) closeSharedMemory() error {
+	if err := t.shm.close(); err != nil {
+		return fmt.Errorf("close LCM transmitter: %w", err)
+	}
+	return nil
+}
+
+// alignShmRecord returns the length rounded up to the record alignment.
+func alignShmRecord(n uint64) uint64 {
+	return (n + shmRecordAlignment - 1) &^ (shmRecordAlignment - 1)
+}
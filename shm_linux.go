@@ -0,0 +1,128 @@
+package lcm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// futex operations.
+const (
+	futexWait = 0
+	futexWake = 1
+)
+
+// createShmRing opens or creates the ring buffer file, and initializes the ring buffer if it is new.
+//
+// The file is locked for the lifetime of the ring, to ensure a single transmitter.
+func createShmRing(path string, capacity uint64) (*shmRing, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, unix.EWOULDBLOCK) {
+			return nil, fmt.Errorf("ring buffer %s already has a transmitter", path)
+		}
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if info.Size() < int64(lengthOfShmHeader+capacity) {
+		// grow new and uninitialized files to fit the ring buffer, and existing ring buffers fail validation below
+		if err := file.Truncate(int64(lengthOfShmHeader + capacity)); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	r, err := mapShmRing(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if r.magic().Load() == 0 {
+		r.initialize(capacity)
+		return r, nil
+	}
+	if err := r.validate(); err != nil {
+		_ = r.close()
+		return nil, err
+	}
+	if r.capacity != capacity {
+		_ = r.close()
+		return nil, fmt.Errorf("ring buffer %s exists with capacity %d", path, r.capacity)
+	}
+	return r, nil
+}
+
+// openShmRing opens an existing ring buffer file.
+func openShmRing(path string) (*shmRing, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	r, err := mapShmRing(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if err := r.validate(); err != nil {
+		_ = r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// mapShmRing maps the ring buffer file into memory.
+func mapShmRing(file *os.File) (*shmRing, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < lengthOfShmHeader {
+		return nil, fmt.Errorf("ring buffer %s is not initialized", file.Name())
+	}
+	mem, err := unix.Mmap(int(file.Fd()), 0, int(info.Size()), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", file.Name(), err)
+	}
+	return &shmRing{file: file, mem: mem}, nil
+}
+
+// wake the receivers waiting for new records.
+func (r *shmRing) wake() {
+	r.notify().Add(1)
+	if r.waiters().Load() > 0 {
+		_, _, _ = unix.Syscall6(
+			unix.SYS_FUTEX, uintptr(unsafe.Pointer(r.notify())), futexWake, math.MaxInt32, 0, 0, 0,
+		)
+	}
+}
+
+// wait until the futex word changes from the provided value, the timeout expires, or a spurious wakeup.
+//
+// A zero timeout waits indefinitely.
+func (r *shmRing) wait(value uint32, timeout time.Duration) {
+	var ts *unix.Timespec
+	if timeout > 0 {
+		t := unix.NsecToTimespec(timeout.Nanoseconds())
+		ts = &t
+	}
+	_, _, _ = unix.Syscall6(
+		unix.SYS_FUTEX, uintptr(unsafe.Pointer(r.notify())), futexWait, uintptr(value), uintptr(unsafe.Pointer(ts)), 0, 0,
+	)
+}
+
+// close the ring buffer mapping and file.
+func (r *shmRing) close() error {
+	return errors.Join(unix.Munmap(r.mem), r.file.Close())
+}
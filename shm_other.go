@@ -0,0 +1,32 @@
+//go:build !linux
+
+package lcm
+
+import (
+	"errors"
+	"time"
+)
+
+// errSharedMemoryUnsupported is returned when opening a shared-memory ring buffer outside Linux.
+var errSharedMemoryUnsupported = errors.New("shared memory is only supported on Linux")
+
+// createShmRing is not supported in non-Linux environments.
+func createShmRing(string, uint64) (*shmRing, error) {
+	return nil, errSharedMemoryUnsupported
+}
+
+// openShmRing is not supported in non-Linux environments.
+func openShmRing(string) (*shmRing, error) {
+	return nil, errSharedMemoryUnsupported
+}
+
+// wake is a no-op in non-Linux environments, where no ring buffer can be opened.
+func (r *shmRing) wake() {}
+
+// wait is a no-op in non-Linux environments, where no ring buffer can be opened.
+func (r *shmRing) wait(uint32, time.Duration) {}
+
+// close is a no-op in non-Linux environments, where no ring buffer can be opened.
+func (r *shmRing) close() error {
+	return nil
+}
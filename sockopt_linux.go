@@ -0,0 +1,117 @@
+package lcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// lengthOfDropCounterControlMessage is the length in bytes of an SO_RXQ_OVFL control message.
+var lengthOfDropCounterControlMessage = unix.CmsgSpace(4)
+
+// enableDropCounter enables reporting of the socket's kernel drop counter in the control messages of received
+// datagrams.
+func enableDropCounter(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("enable drop counter: %w", err)
+	}
+	var sockoptErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockoptErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_RXQ_OVFL, 1)
+	}); err != nil {
+		return fmt.Errorf("enable drop counter: %w", err)
+	}
+	if sockoptErr != nil {
+		return fmt.Errorf("enable drop counter: %w", sockoptErr)
+	}
+	return nil
+}
+
+// parseDropCounter parses the kernel drop counter from the control messages of a received datagram.
+//
+// The kernel only includes the drop counter after the first drop on the socket.
+func parseDropCounter(oob []byte) (uint32, bool) {
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0, false
+	}
+	for _, m := range messages {
+		if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SO_RXQ_OVFL && len(m.Data) >= 4 {
+			return binary.NativeEndian.Uint32(m.Data), true
+		}
+	}
+	return 0, false
+}
+
+// setReusePort enables SO_REUSEPORT on a socket before it is bound, for use as a net.ListenConfig control function.
+func setReusePort(_, _ string, rawConn syscall.RawConn) error {
+	var sockoptErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockoptErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	}); err != nil {
+		return fmt.Errorf("set reuse port: %w", err)
+	}
+	if sockoptErr != nil {
+		return fmt.Errorf("set reuse port: %w", sockoptErr)
+	}
+	return nil
+}
+
+// attachReusePortBPF attaches a socket selection program to the SO_REUSEPORT group of the connection.
+func attachReusePortBPF(conn *net.UDPConn, program []bpf.RawInstruction) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("attach reuse port bpf: %w", err)
+	}
+	filter := make([]unix.SockFilter, 0, len(program))
+	for _, instruction := range program {
+		filter = append(filter, unix.SockFilter{
+			Code: instruction.Op,
+			Jt:   instruction.Jt,
+			Jf:   instruction.Jf,
+			K:    instruction.K,
+		})
+	}
+	fprog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	var sockoptErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockoptErr = unix.SetsockoptSockFprog(int(fd), unix.SOL_SOCKET, unix.SO_ATTACH_REUSEPORT_CBPF, &fprog)
+	}); err != nil {
+		return fmt.Errorf("attach reuse port bpf: %w", err)
+	}
+	if sockoptErr != nil {
+		return fmt.Errorf("attach reuse port bpf: %w", sockoptErr)
+	}
+	return nil
+}
+
+// unixgramTransmitterAddr returns the address of the socket of a Unix datagram transmitter, in the abstract
+// namespace so that receivers can tell transmitters apart without a file in the socket directory.
+func unixgramTransmitterAddr() *net.UnixAddr {
+	return &net.UnixAddr{Name: "@lcm." + unixgramSocketName(), Net: "unixgram"}
+}
+
+// sendUnixgram transmits a datagram to a Unix datagram socket without blocking, failing with EAGAIN when the receive
+// buffer of the socket is full.
+func sendUnixgram(conn *net.UnixConn, b []byte, addr *net.UnixAddr) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("send unixgram: %w", err)
+	}
+	var sendErr error
+	if err := rawConn.Write(func(fd uintptr) bool {
+		sendErr = unix.Sendto(int(fd), b, unix.MSG_DONTWAIT, &unix.SockaddrUnix{Name: addr.Name})
+		return true
+	}); err != nil {
+		return fmt.Errorf("send unixgram: %w", err)
+	}
+	if sendErr != nil {
+		return fmt.Errorf("send unixgram: %w", sendErr)
+	}
+	return nil
+}
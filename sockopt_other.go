@@ -0,0 +1,48 @@
+//go:build !linux
+
+package lcm
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/bpf"
+)
+
+// lengthOfDropCounterControlMessage is the length in bytes of a drop counter control message.
+//
+// Drop counters are only supported on Linux.
+const lengthOfDropCounterControlMessage = 0
+
+// enableDropCounter is a no-op in non-Linux environments.
+func enableDropCounter(*net.UDPConn) error {
+	return nil
+}
+
+// parseDropCounter is a no-op in non-Linux environments.
+func parseDropCounter([]byte) (uint32, bool) {
+	return 0, false
+}
+
+// setReusePort is not supported in non-Linux environments.
+func setReusePort(string, string, syscall.RawConn) error {
+	return errors.New("set reuse port: only supported on Linux")
+}
+
+// attachReusePortBPF is not supported in non-Linux environments.
+func attachReusePortBPF(*net.UDPConn, []bpf.RawInstruction) error {
+	return errors.New("attach reuse port bpf: only supported on Linux")
+}
+
+// unixgramTransmitterAddr returns the address of the socket of a Unix datagram transmitter, which is unnamed in
+// non-Linux environments without an abstract namespace.
+func unixgramTransmitterAddr() *net.UnixAddr {
+	return &net.UnixAddr{Net: "unixgram"}
+}
+
+// sendUnixgram transmits a datagram to a Unix datagram socket, blocking while the receive buffer of the socket is full.
+func sendUnixgram(conn *net.UnixConn, b []byte, addr *net.UnixAddr) error {
+	_, err := conn.WriteToUnix(b, addr)
+	return err
+}
@@ -0,0 +1,251 @@
+package lcm
+
+import (
+	"bytes"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sequenceWindow is the number of most recent sequence numbers remembered per sender.
+//
+// The window is used to tell late (reordered) messages apart from duplicates and sender restarts.
+const sequenceWindow = 64
+
+// senderTimeout is the time after which a sender that has not been received from is forgotten.
+//
+// The sequence number statistics of forgotten senders remain in the totals of the receiver.
+const senderTimeout = time.Minute
+
+// SequenceStats are sequence number statistics for received LCM messages.
+//
+// LCM transmitters use a single sequence number for all channels, so the statistics are only accurate when the
+// receiver observes every channel of a sender. Messages on channels rejected by the receiver's filter are counted
+// as missing.
+type SequenceStats struct {
+	// Received is the number of received messages.
+	Received uint64
+	// Missing is the number of skipped sequence numbers that have not been received (yet).
+	//
+	// Only meaningful for receivers without channel filters. When configured with WithReceiveChannels,
+	// WithReceiveProtos or a BPF filter, the messages on other channels are dropped by the kernel before they are
+	// observed, and their sequence numbers are counted as missing.
+	Missing uint64
+	// Duplicates is the number of received messages with an already received sequence number.
+	Duplicates uint64
+	// Reordered is the number of messages received after a message with a later sequence number.
+	Reordered uint64
+	// Restarts is the number of times a sender's sequence number has been reset.
+	//
+	// A sender is restarted when its sequence number jumps back further than the 64 most recent sequence numbers, or
+	// when two consecutive messages repeat already received sequence numbers in order.
+	Restarts uint64
+}
+
+func (s *SequenceStats) add(other SequenceStats) {
+	s.Received += other.Received
+	s.Missing += other.Missing
+	s.Duplicates += other.Duplicates
+	s.Reordered += other.Reordered
+	s.Restarts += other.Restarts
+}
+
+// SenderStats are receive statistics for a single LCM transmitter, identified by its source address and port, or by
+// its socket path for Unix datagram receivers.
+type SenderStats struct {
+	// Address is the source address of the sender.
+	Address net.IP
+	// Port is the source port of the sender.
+	Port int
+	// Path is the socket path of the sender of a Unix datagram receiver, where abstract socket names start with '@'.
+	//
+	// Empty for senders without a socket name, which are not told apart.
+	Path string
+	SequenceStats
+	// Channels is the number of received messages per channel.
+	Channels map[string]uint64
+}
+
+// ReceiverStats are receive statistics for an LCM receiver.
+type ReceiverStats struct {
+	// SequenceStats are the sequence number statistics summed over all senders.
+	SequenceStats
+	// Senders are the statistics for each sender. Senders that have not been received from for a minute are
+	// forgotten, and only remain in the summed statistics.
+	Senders []SenderStats
+	// Discarded is the number of datagrams discarded by the kernel, because the socket receive buffer was full or
+	// because the datagram was rejected by the receiver's filter.
+	//
+	// The kernel counts both in the same per-socket counter, so for receivers that filter channels, which is the
+	// default, the count includes the datagrams on other channels. For shared-memory receivers, it is the number of
+	// messages overwritten before they could be received.
+	Discarded uint64
+	// BatchDiscarded is the number of datagrams discarded before the most recently read batch.
+	//
+	// For receivers that don't filter channels, a non-zero value indicates that the receive buffer size or the batch
+	// size is too small.
+	BatchDiscarded uint64
+	// Malformed is the number of received datagrams that could not be decoded.
+	Malformed uint64
+}
+
+// senderState is the sequence number tracking state for a single sender.
+type senderState struct {
+	stats    SequenceStats
+	channels map[string]uint64
+	last     uint32
+	// window is a bitmap of received sequence numbers, where bit i is set if last-i has been received.
+	window uint64
+	// repeated is set when the last message repeated an already received sequence number, behind the last one.
+	repeated       bool
+	repeatedNumber uint32
+	lastSeen       time.Time
+}
+
+// observe a message with the provided sequence number from the sender.
+func (s *senderState) observe(sequenceNumber uint32) {
+	s.stats.Received++
+	repeated := s.repeated
+	s.repeated = false
+	if s.window == 0 {
+		s.last, s.window = sequenceNumber, 1
+		return
+	}
+	switch diff := int64(int32(sequenceNumber - s.last)); {
+	case diff > 0:
+		s.stats.Missing += uint64(diff - 1)
+		if diff < sequenceWindow {
+			s.window = s.window<<diff | 1
+		} else {
+			s.window = 1
+		}
+		s.last = sequenceNumber
+	case diff == 0:
+		s.stats.Duplicates++
+	case -diff < sequenceWindow:
+		bit := uint64(1) << -diff
+		if s.window&bit != 0 {
+			if repeated && sequenceNumber == s.repeatedNumber+1 {
+				// a restarted sender repeating sequence numbers, rather than a duplicate
+				s.stats.Duplicates--
+				s.stats.Restarts++
+				s.last, s.window = sequenceNumber, 0b11
+				return
+			}
+			s.stats.Duplicates++
+			s.repeated, s.repeatedNumber = true, sequenceNumber
+			return
+		}
+		s.window |= bit
+		s.stats.Reordered++
+		if s.stats.Missing > 0 {
+			s.stats.Missing--
+		}
+	default:
+		s.stats.Restarts++
+		s.last, s.window = sequenceNumber, 1
+	}
+}
+
+// senderSource identifies the sender of a received message, by its source address and port, or by its socket path.
+type senderSource struct {
+	addrPort netip.AddrPort
+	path     string
+}
+
+// receiverStats tracks receive statistics for a receiver.
+//
+// Thread-safe.
+type receiverStats struct {
+	mu      sync.Mutex
+	senders map[senderSource]*senderState
+	// forgotten are the summed sequence number statistics of the senders forgotten after senderTimeout.
+	forgotten      SequenceStats
+	lastForget     time.Time
+	discarded      uint64
+	batchDiscarded uint64
+	malformed      uint64
+}
+
+// observe a message received at the provided time.
+func (r *receiverStats) observe(source senderSource, channel string, sequenceNumber uint32, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.lastForget) >= senderTimeout {
+		r.forgetIdle(now)
+	}
+	sender, ok := r.senders[source]
+	if !ok {
+		if r.senders == nil {
+			r.senders = make(map[senderSource]*senderState)
+		}
+		sender = &senderState{channels: make(map[string]uint64)}
+		r.senders[source] = sender
+	}
+	sender.observe(sequenceNumber)
+	sender.channels[channel]++
+	sender.lastSeen = now
+}
+
+// forgetIdle forgets the senders that have not been received from within senderTimeout of the provided time.
+func (r *receiverStats) forgetIdle(now time.Time) {
+	r.lastForget = now
+	for source, sender := range r.senders {
+		if now.Sub(sender.lastSeen) >= senderTimeout {
+			r.forgotten.add(sender.stats)
+			delete(r.senders, source)
+		}
+	}
+}
+
+// observeDiscards observes the number of kernel discards detected in a batch read.
+func (r *receiverStats) observeDiscards(batchDiscarded uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discarded += batchDiscarded
+	r.batchDiscarded = batchDiscarded
+}
+
+// observeMalformed observes a malformed message.
+func (r *receiverStats) observeMalformed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.malformed++
+}
+
+// snapshot returns a copy of the current statistics.
+func (r *receiverStats) snapshot() ReceiverStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := ReceiverStats{
+		SequenceStats:  r.forgotten,
+		Discarded:      r.discarded,
+		BatchDiscarded: r.batchDiscarded,
+		Malformed:      r.malformed,
+	}
+	result.Senders = make([]SenderStats, 0, len(r.senders))
+	for source, sender := range r.senders {
+		result.Senders = append(result.Senders, SenderStats{
+			Address:       net.IP(source.addrPort.Addr().AsSlice()),
+			Port:          int(source.addrPort.Port()),
+			Path:          source.path,
+			SequenceStats: sender.stats,
+			Channels:      maps.Clone(sender.channels),
+		})
+		result.add(sender.stats)
+	}
+	slices.SortFunc(result.Senders, func(a, b SenderStats) int {
+		if c := bytes.Compare(a.Address, b.Address); c != 0 {
+			return c
+		}
+		if c := a.Port - b.Port; c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+	return result
+}
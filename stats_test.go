@@ -0,0 +1,125 @@
+package lcm
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSenderState_Observe(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		sequenceNumbers []uint32
+		expected        SequenceStats
+	}{
+		{
+			name:            "in order",
+			sequenceNumbers: []uint32{0, 1, 2, 3},
+			expected:        SequenceStats{Received: 4},
+		},
+		{
+			name:            "gap",
+			sequenceNumbers: []uint32{0, 1, 4, 5},
+			expected:        SequenceStats{Received: 4, Missing: 2},
+		},
+		{
+			name:            "duplicate",
+			sequenceNumbers: []uint32{0, 1, 1, 2, 0},
+			expected:        SequenceStats{Received: 5, Duplicates: 2},
+		},
+		{
+			name:            "reordered",
+			sequenceNumbers: []uint32{0, 2, 1, 3},
+			expected:        SequenceStats{Received: 4, Reordered: 1},
+		},
+		{
+			name:            "restart",
+			sequenceNumbers: []uint32{1000, 1001, 0, 1},
+			expected:        SequenceStats{Received: 4, Restarts: 1},
+		},
+		{
+			name:            "restart within window",
+			sequenceNumbers: []uint32{0, 1, 2, 3, 0, 1, 2},
+			expected:        SequenceStats{Received: 7, Restarts: 1},
+		},
+		{
+			name:            "repeated duplicates",
+			sequenceNumbers: []uint32{0, 1, 2, 3, 1, 4, 2, 5},
+			expected:        SequenceStats{Received: 8, Duplicates: 2},
+		},
+		{
+			name:            "wraparound",
+			sequenceNumbers: []uint32{0xfffffffe, 0xffffffff, 0, 1},
+			expected:        SequenceStats{Received: 4},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var s senderState
+			for _, sequenceNumber := range tt.sequenceNumbers {
+				s.observe(sequenceNumber)
+			}
+			assert.Equal(t, tt.expected, s.stats)
+		})
+	}
+}
+
+func TestReceiverStats_Snapshot(t *testing.T) {
+	var stats receiverStats
+	now := time.Unix(1000, 0)
+	source1 := senderSource{addrPort: netip.MustParseAddrPort("10.0.0.1:1234")}
+	source2 := senderSource{addrPort: netip.MustParseAddrPort("10.0.0.2:1234")}
+	source3 := senderSource{path: "@lcm.1.1"}
+	stats.observe(source2, "foo", 0, now)
+	stats.observe(source1, "foo", 0, now)
+	stats.observe(source1, "bar", 2, now)
+	stats.observe(source3, "foo", 5, now)
+	assert.DeepEqual(t, ReceiverStats{
+		SequenceStats: SequenceStats{Received: 4, Missing: 1},
+		Senders: []SenderStats{
+			{
+				Path:          "@lcm.1.1",
+				SequenceStats: SequenceStats{Received: 1},
+				Channels:      map[string]uint64{"foo": 1},
+			},
+			{
+				Address:       net.IPv4(10, 0, 0, 1).To4(),
+				Port:          1234,
+				SequenceStats: SequenceStats{Received: 2, Missing: 1},
+				Channels:      map[string]uint64{"foo": 1, "bar": 1},
+			},
+			{
+				Address:       net.IPv4(10, 0, 0, 2).To4(),
+				Port:          1234,
+				SequenceStats: SequenceStats{Received: 1},
+				Channels:      map[string]uint64{"foo": 1},
+			},
+		},
+	}, stats.snapshot())
+}
+
+func TestReceiverStats_ForgetIdle(t *testing.T) {
+	var stats receiverStats
+	now := time.Unix(1000, 0)
+	source1 := senderSource{addrPort: netip.MustParseAddrPort("10.0.0.1:1234")}
+	source2 := senderSource{addrPort: netip.MustParseAddrPort("10.0.0.2:1234")}
+	stats.observe(source1, "foo", 0, now)
+	stats.observe(source1, "foo", 2, now)
+	stats.observe(source2, "foo", 0, now.Add(senderTimeout/2))
+	// when a sender has been idle for the timeout
+	stats.observe(source2, "foo", 1, now.Add(senderTimeout))
+	// then it should be forgotten, and remain in the totals
+	snapshot := stats.snapshot()
+	assert.Equal(t, SequenceStats{Received: 4, Missing: 1}, snapshot.SequenceStats)
+	assert.Equal(t, 1, len(snapshot.Senders))
+	assert.Equal(t, 1234, snapshot.Senders[0].Port)
+	assert.DeepEqual(t, net.IPv4(10, 0, 0, 2).To4(), snapshot.Senders[0].Address)
+	// and a forgotten sender should start over when received from again
+	stats.observe(source1, "foo", 0, now.Add(senderTimeout))
+	snapshot = stats.snapshot()
+	assert.Equal(t, SequenceStats{Received: 5, Missing: 1}, snapshot.SequenceStats)
+	assert.Equal(t, 2, len(snapshot.Senders))
+	assert.Equal(t, SequenceStats{Received: 1}, snapshot.Senders[0].SequenceStats)
+}
@@ -0,0 +1,110 @@
+package lcm
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"go.einride.tech/lcm/lcmbpf"
+	"google.golang.org/protobuf/proto"
+)
+
+// subscriptionSource is a set of the ways a channel pattern has been subscribed to.
+type subscriptionSource uint8
+
+const (
+	// subscribedChannel is set for channel patterns subscribed to with WithReceiveChannels or AddChannels.
+	subscribedChannel subscriptionSource = 1 << iota
+	// subscribedProto is set for the channels of proto messages subscribed to with WithReceiveProtos or AddProtos.
+	subscribedProto
+)
+
+// channelSubscription is the set of channels subscribed to by a receiver.
+type channelSubscription struct {
+	// sources are the subscribed channel patterns, with the ways they have been subscribed to.
+	sources map[string]subscriptionSource
+	// patterns are the subscribed channel patterns with wildcards, in subscription order.
+	patterns []string
+	// filtered is set once a channel has been subscribed to. A filtered subscription without channels receives no
+	// channels.
+	filtered bool
+}
+
+// newChannelSubscription returns a subscription to the channel patterns and the channels of the proto messages.
+func newChannelSubscription(patterns []string, protos []proto.Message) *channelSubscription {
+	s := &channelSubscription{sources: make(map[string]subscriptionSource)}
+	for _, pattern := range patterns {
+		s.add(pattern, subscribedChannel)
+	}
+	for _, msg := range protos {
+		s.add(string(msg.ProtoReflect().Descriptor().FullName()), subscribedProto)
+	}
+	return s
+}
+
+// add a channel pattern to the subscription.
+func (s *channelSubscription) add(pattern string, source subscriptionSource) {
+	s.filtered = true
+	existing, ok := s.sources[pattern]
+	s.sources[pattern] = existing | source
+	if !ok && strings.ContainsRune(pattern, '*') {
+		s.patterns = append(s.patterns, pattern)
+	}
+}
+
+// remove a channel pattern from the subscription, unless it is still subscribed to in another way.
+func (s *channelSubscription) remove(pattern string, source subscriptionSource) {
+	existing, ok := s.sources[pattern]
+	if !ok {
+		return
+	}
+	if existing &^= source; existing != 0 {
+		s.sources[pattern] = existing
+		return
+	}
+	delete(s.sources, pattern)
+	s.patterns = slices.DeleteFunc(s.patterns, func(p string) bool {
+		return p == pattern
+	})
+}
+
+// clone returns a copy of the subscription.
+func (s *channelSubscription) clone() *channelSubscription {
+	return &channelSubscription{sources: maps.Clone(s.sources), patterns: slices.Clone(s.patterns), filtered: s.filtered}
+}
+
+// receivesAll reports if no channel has ever been subscribed to, in which case all channels are received.
+func (s *channelSubscription) receivesAll() bool {
+	return !s.filtered
+}
+
+// matches reports if the channel is subscribed to.
+func (s *channelSubscription) matches(channel string) bool {
+	if s.receivesAll() {
+		return true
+	}
+	if _, ok := s.sources[channel]; ok {
+		return true
+	}
+	for _, pattern := range s.patterns {
+		if lcmbpf.MatchChannel(pattern, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// condition returns the BPF filter condition for the subscription.
+func (s *channelSubscription) condition() lcmbpf.Condition {
+	if len(s.sources) == 0 {
+		return lcmbpf.Any() // never holds
+	}
+	patterns := make([]string, 0, len(s.sources))
+	for pattern := range s.sources {
+		if !strings.ContainsRune(pattern, '*') {
+			patterns = append(patterns, pattern)
+		}
+	}
+	slices.Sort(patterns)
+	return lcmbpf.ChannelPattern(append(patterns, s.patterns...)...)
+}
@@ -3,8 +3,12 @@ package lcm
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"sync"
+	"time"
 
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/nettest"
@@ -12,9 +16,22 @@ import (
 )
 
 // Transmitter represents an LCM Transmitter instance.
+//
+// Safe for concurrent use.
 type Transmitter struct {
-	opts           *transmitterOptions
+	opts *transmitterOptions
+	// mu serializes transmits, which share the buffers and the sequence number.
+	mu             sync.Mutex
+	schemas        schemaSet
+	channels       map[string]string
+	closed         chan struct{}
+	background     sync.WaitGroup
+	closeOnce      sync.Once
+	closeErr       error
 	conn           *ipv4.PacketConn
+	unixConn       *net.UnixConn
+	peers          *unixgramPeers
+	shm            *shmRing
 	sequenceNumber uint32
 	messageBuf     []ipv4.Message
 	payloadBuf     [lengthOfLargestUDPMessage]byte
@@ -29,7 +46,9 @@ type Compressor interface {
 }
 
 // DialMulticastUDP returns a Transmitter configured with the provided options.
-func DialMulticastUDP(ctx context.Context, transmitterOpts ...TransmitterOption) (*Transmitter, error) {
+//
+// The interfaces configured with WithTransmitInterface must be up and support multicast.
+func DialMulticastUDP(ctx context.Context, transmitterOpts ...TransmitterOption) (_ *Transmitter, err error) {
 	opts := defaultTransmitterOptions()
 	for _, transmitterOpt := range transmitterOpts {
 		transmitterOpt(opts)
@@ -40,23 +59,32 @@ func DialMulticastUDP(ctx context.Context, transmitterOpts ...TransmitterOption)
 		return nil, fmt.Errorf("dial multicast UDP: %w", err)
 	}
 	udpConn := c.(*net.UDPConn)
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, udpConn.Close())
+		}
+	}()
 	conn := ipv4.NewPacketConn(udpConn)
 	if err := conn.SetMulticastTTL(opts.ttl); err != nil {
 		return nil, fmt.Errorf("dial multicast UDP: %w", err)
 	}
-	var ifi *net.Interface
-	if opts.interfaceName != "" {
-		ifi, err = net.InterfaceByName(opts.interfaceName)
+	var ifis []*net.Interface
+	for _, interfaceName := range opts.interfaceNames {
+		ifi, err := multicastInterfaceByName(interfaceName)
 		if err != nil {
-			return nil, fmt.Errorf("dial multicast UDP: failed to lookup provided if: %w", err)
+			return nil, fmt.Errorf("dial multicast UDP: %w", err)
 		}
-	} else {
-		ifi, err = getMulticastInterface()
+		ifis = append(ifis, ifi)
+	}
+	if len(ifis) == 0 {
+		ifi, err := getMulticastInterface()
 		if err != nil {
 			return nil, fmt.Errorf("dial multicast UDP: failed to lookup multicast if: %w", err)
 		}
+		opts.logger.LogAttrs(ctx, slog.LevelDebug, "selected multicast interface", interfaceAttr(ifi))
+		ifis = append(ifis, ifi)
 	}
-	if err := conn.SetMulticastInterface(ifi); err != nil {
+	if err := conn.SetMulticastInterface(ifis[0]); err != nil {
 		return nil, fmt.Errorf("dial multicast UDP: %w", err)
 	}
 	if err := conn.SetMulticastLoopback(opts.loopback); err != nil {
@@ -66,13 +94,67 @@ func DialMulticastUDP(ctx context.Context, transmitterOpts ...TransmitterOption)
 	if len(opts.addrs) == 0 {
 		opts.addrs = append(opts.addrs, &net.UDPAddr{IP: DefaultMulticastIP(), Port: DefaultPort})
 	}
-	for _, addr := range opts.addrs {
-		tx.messageBuf = append(tx.messageBuf, ipv4.Message{
+	interfaceNames := make([]string, 0, len(ifis))
+	for _, ifi := range ifis {
+		// the interface of each message is selected with a control message when transmitting on multiple interfaces
+		var oob []byte
+		if len(ifis) > 1 {
+			oob = (&ipv4.ControlMessage{IfIndex: ifi.Index}).Marshal()
+		}
+		tx.messageBuf = appendTransmitMessages(tx.messageBuf, opts.addrs, oob)
+		interfaceNames = append(interfaceNames, ifi.Name)
+	}
+	opts.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"transmitting LCM messages",
+		slog.Any("interfaces", interfaceNames),
+		slog.Any("addresses", opts.addrs),
+		slog.Int("ttl", opts.ttl),
+		slog.Bool("loopback", opts.loopback),
+	)
+	tx.startBackground()
+	return tx, nil
+}
+
+// DialUDP returns a Transmitter for unicast and broadcast destinations, configured with the provided options.
+//
+// At least one address to transmit to must be configured with WithTransmitAddress. The multicast options, such as
+// the interfaces and the TTL, are ignored.
+func DialUDP(ctx context.Context, transmitterOpts ...TransmitterOption) (*Transmitter, error) {
+	opts := defaultTransmitterOptions()
+	for _, transmitterOpt := range transmitterOpts {
+		transmitterOpt(opts)
+	}
+	if len(opts.addrs) == 0 {
+		return nil, errors.New("dial UDP: no addresses to transmit to")
+	}
+	var listenConfig net.ListenConfig
+	c, err := listenConfig.ListenPacket(ctx, "udp4", "")
+	if err != nil {
+		return nil, fmt.Errorf("dial UDP: %w", err)
+	}
+	udpConn := c.(*net.UDPConn)
+	if err := enableBroadcast(udpConn); err != nil {
+		return nil, fmt.Errorf("dial UDP: %w", errors.Join(err, udpConn.Close()))
+	}
+	tx := &Transmitter{opts: opts, conn: ipv4.NewPacketConn(udpConn)}
+	tx.messageBuf = appendTransmitMessages(tx.messageBuf, opts.addrs, nil)
+	opts.logger.LogAttrs(ctx, slog.LevelDebug, "transmitting LCM messages", slog.Any("addresses", opts.addrs))
+	tx.startBackground()
+	return tx, nil
+}
+
+// appendTransmitMessages appends a message to each address, with the provided control message, to the batch.
+func appendTransmitMessages(messages []ipv4.Message, addrs []*net.UDPAddr, oob []byte) []ipv4.Message {
+	for _, addr := range addrs {
+		messages = append(messages, ipv4.Message{
 			Buffers: [][]byte{nil},
+			OOB:     oob,
 			Addr:    addr,
 		})
 	}
-	return tx, nil
+	return messages
 }
 
 // getMulticastInterface retrieves a multicast enabled interface to transmit on.
@@ -95,67 +177,230 @@ func (t *Transmitter) TransmitProto(ctx context.Context, m proto.Message) error
 
 // TransmitProto transmits a protobuf message.
 func (t *Transmitter) TransmitProtoOnChannel(ctx context.Context, channel string, m proto.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.opts.schemas {
+		if err := t.observeSchema(ctx, m.ProtoReflect().Descriptor()); err != nil {
+			t.opts.logger.LogAttrs(ctx, slog.LevelWarn, "failed to publish schema", slog.Any("error", err))
+		}
+	}
 	// Reuse a buffer to avoid data allocation of a new byte slice every time
 	t.protoBuf.Reset()
 	b, err := proto.MarshalOptions{}.MarshalAppend(t.protoBuf.Bytes(), m)
 	if err != nil {
+		t.opts.metrics.TransmitFailed(channel)
 		return fmt.Errorf("transmit proto on channel %s: %w", channel, err)
 	}
-	return t.Transmit(ctx, channel, b)
+	name := string(m.ProtoReflect().Descriptor().FullName())
+	var params string
+	if t.opts.protoTypeParam && channel != name {
+		params = paramType + "=" + name
+	}
+	t.observeChannel(channel, name)
+	return t.transmit(ctx, channel, params, b)
 }
 
 // Transmit a raw payload.
 //
 // If the provided context has a deadline, it will be propagated to the underlying write operation.
 func (t *Transmitter) Transmit(ctx context.Context, channel string, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transmit(ctx, channel, "", data)
+}
+
+// transmit a raw payload with the provided channel params, in addition to the compression param.
+func (t *Transmitter) transmit(ctx context.Context, channel, params string, data []byte) error {
+	start := time.Now()
 	if compressor := t.opts.compressor[channel]; compressor != nil {
 		compressed, err := compressor.Compress(data)
 		if err != nil {
-			return fmt.Errorf("transmit compress: %w", err)
+			return t.transmitFailed(ctx, channel, fmt.Errorf("transmit compress: %w", err))
 		}
 		t.msg.Data = compressed
-		t.msg.Params = "z=" + compressor.Name()
+		t.msg.Params = paramCompression + "=" + compressor.Name()
+		if params != "" {
+			t.msg.Params += "&" + params
+		}
 	} else {
 		t.msg.Data = data
-		t.msg.Params = ""
+		t.msg.Params = params
 	}
-
 	t.msg.Channel = channel
 	t.msg.SequenceNumber = t.sequenceNumber
 	t.sequenceNumber++
-	n, err := t.msg.marshal(t.payloadBuf[:])
+	return t.transmitMessage(ctx, &t.msg, start)
+}
+
+// TransmitMessage transmits a fully specified message as-is.
+//
+// The channel, params, sequence number and data of the message are transmitted unchanged, without compression, and
+// the sequence number of the transmitter is not incremented. This lets bridges and log players forward messages
+// faithfully.
+//
+// If the provided context has a deadline, it will be propagated to the underlying write operation.
+func (t *Transmitter) TransmitMessage(ctx context.Context, m *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.transmitMessage(ctx, m, time.Now())
+}
+
+// transmitMessage transmits a message and reports the outcome to the metrics.
+func (t *Transmitter) transmitMessage(ctx context.Context, m *Message, start time.Time) error {
+	t.observeChannel(m.Channel, m.Param(paramType))
+	n, err := t.marshalAndWrite(ctx, m)
 	if err != nil {
-		return fmt.Errorf("transmit to LCM: %w", err)
+		return t.transmitFailed(ctx, m.Channel, err)
+	}
+	t.opts.metrics.MessageTransmitted(m.Channel, n, time.Since(start))
+	return nil
+}
+
+// transmitFailed reports a failed transmit to the metrics and the logger, and returns the error.
+func (t *Transmitter) transmitFailed(ctx context.Context, channel string, err error) error {
+	t.opts.metrics.TransmitFailed(channel)
+	t.opts.logger.LogAttrs(
+		ctx, slog.LevelDebug, "failed to transmit message", slog.String("channel", channel), slog.Any("error", err),
+	)
+	return err
+}
+
+// TransmitRaw transmits a datagram as-is, such as a datagram received with Receiver.ReceiveRaw.
+//
+// The datagram must be an LCM message. It is transmitted without copying, compression or renumbering.
+//
+// If the provided context has a deadline, it will be propagated to the underlying write operation.
+func (t *Transmitter) TransmitRaw(ctx context.Context, datagram []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start := time.Now()
+	var m Message
+	if err := m.unmarshal(datagram); err != nil {
+		return t.transmitFailed(ctx, "", fmt.Errorf("transmit raw to LCM: %w", err))
+	}
+	t.observeChannel(m.Channel, m.Param(paramType))
+	if err := t.write(ctx, datagram); err != nil {
+		return t.transmitFailed(ctx, m.Channel, err)
+	}
+	t.opts.metrics.MessageTransmitted(m.Channel, len(datagram), time.Since(start))
+	return nil
+}
+
+// marshalAndWrite transmits a message and returns the size of the transmitted datagram.
+func (t *Transmitter) marshalAndWrite(ctx context.Context, m *Message) (int, error) {
+	if t.shm != nil {
+		return t.transmitSharedMemory(m)
+	}
+	n, err := m.marshal(t.payloadBuf[:])
+	if err != nil {
+		return 0, fmt.Errorf("transmit to LCM: %w", err)
+	}
+	if err := t.write(ctx, t.payloadBuf[:n]); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// write a datagram to every destination of the transmitter.
+func (t *Transmitter) write(ctx context.Context, datagram []byte) error {
+	if t.shm != nil {
+		return t.writeSharedMemory(datagram)
 	}
 	for i := range t.messageBuf {
-		t.messageBuf[i].Buffers[0] = t.payloadBuf[:n]
-		t.messageBuf[i].N = n
+		t.messageBuf[i].Buffers[0] = datagram
+		t.messageBuf[i].N = len(datagram)
+	}
+	if t.unixConn != nil {
+		return t.transmitUnixgram(ctx, datagram)
 	}
 	deadline, _ := ctx.Deadline()
 	if err := t.conn.SetWriteDeadline(deadline); err != nil {
 		return fmt.Errorf("transmit to LCM: %w", err)
 	}
-	// fast-path: transmit to single address
+	// fast-path: transmit to single address on a single interface
 	if len(t.messageBuf) == 1 {
-		if _, err := t.conn.WriteTo(t.messageBuf[0].Buffers[0], nil, t.messageBuf[0].Addr); err != nil {
+		if _, err := t.conn.WriteTo(datagram, nil, t.messageBuf[0].Addr); err != nil {
 			return fmt.Errorf("transmit to LCM: %w", err)
 		}
 		return nil
 	}
-	// transmit to multiple addresses
+	// transmit to multiple addresses or interfaces
 	var transmitCount int
 	for transmitCount < len(t.messageBuf) {
-		n, err := t.conn.WriteBatch(t.messageBuf[transmitCount:], 0)
+		sent, err := t.conn.WriteBatch(t.messageBuf[transmitCount:], 0)
 		if err != nil {
 			return fmt.Errorf("transmit to LCM: %w", err)
 		}
-		transmitCount += n
+		transmitCount += sent
 	}
 	return nil
 }
 
-// Close the transmitter connection.
+// startBackground starts publishing schemas and announcements periodically, if configured to.
+//
+// Both start immediately: schemas are published at Dial, which publishes nothing until a proto message type has been
+// transmitted, and then at every interval.
+func (t *Transmitter) startBackground() {
+	if t.opts.schemas && t.opts.schemaInterval > 0 {
+		t.runPeriodically(t.opts.schemaInterval, "failed to publish schemas", func(ctx context.Context) error {
+			return t.publishSchemas(ctx)
+		})
+	}
+	if t.opts.announceInterval > 0 {
+		t.runPeriodically(t.opts.announceInterval, "failed to announce", t.announce)
+	}
+}
+
+// runPeriodically runs the function in a goroutine at the interval, starting immediately, until the transmitter is
+// closed.
+func (t *Transmitter) runPeriodically(interval time.Duration, failure string, f func(context.Context) error) {
+	if t.closed == nil {
+		t.closed = make(chan struct{})
+	}
+	t.background.Add(1)
+	go func() {
+		defer t.background.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ctx := context.Background()
+		for {
+			if err := f(ctx); err != nil {
+				t.opts.logger.LogAttrs(ctx, slog.LevelWarn, failure, slog.Any("error", err))
+			}
+			select {
+			case <-t.closed:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close the transmitter connection, after stopping the background publishing of schemas and announcements.
+//
+// Closing a closed transmitter returns the result of the first Close.
 func (t *Transmitter) Close() error {
+	t.closeOnce.Do(func() {
+		t.closeErr = t.close()
+	})
+	return t.closeErr
+}
+
+// close the transmitter connection.
+func (t *Transmitter) close() error {
+	if t.closed != nil {
+		close(t.closed)
+		t.background.Wait()
+	}
+	if t.shm != nil {
+		return t.closeSharedMemory()
+	}
+	if t.unixConn != nil {
+		if err := t.unixConn.Close(); err != nil {
+			return fmt.Errorf("close LCM transmitter: %w", err)
+		}
+		return nil
+	}
 	if err := t.conn.Close(); err != nil {
 		return fmt.Errorf("close LCM transmitter: %w", err)
 	}
@@ -1,36 +1,55 @@
 package lcm
 
 import (
+	"log/slog"
 	"net"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 )
 
 // transmitterOptions are the configuration options for an LCM transmitter.
 type transmitterOptions struct {
-	ttl           int
-	loopback      bool
-	compressor    map[string]Compressor
-	interfaceName string
-	addrs         []*net.UDPAddr
+	ttl            int
+	loopback       bool
+	compressor     map[string]Compressor
+	protoTypeParam bool
+	schemas        bool
+	schemaInterval time.Duration
+	// nodeName is the node name of announcements, published at announceInterval.
+	nodeName         string
+	announceInterval time.Duration
+	interfaceNames   []string
+	addrs            []*net.UDPAddr
+	// sharedMemorySize is the size of the ring buffer of a shared-memory transmitter.
+	sharedMemorySize int
+	metrics          TransmitterMetrics
+	logger           *slog.Logger
 }
 
 // defaultTransmitterOptions returns transmitter options with sensible default values.
 func defaultTransmitterOptions() *transmitterOptions {
 	return &transmitterOptions{
-		loopback:   true,
-		ttl:        1,
-		compressor: make(map[string]Compressor),
+		loopback:         true,
+		ttl:              1,
+		compressor:       make(map[string]Compressor),
+		sharedMemorySize: 64 << 20, // 64MB
+		metrics:          noopMetrics{},
+		logger:           slog.New(slog.DiscardHandler),
 	}
 }
 
 // TransmitterOption configures an LCM transmitter.
 type TransmitterOption func(*transmitterOptions)
 
-// WithTransmitInterface configures the interface to transmit on.
+// WithTransmitInterface configures an interface to transmit on.
+//
+// Provide this option multiple times to transmit each message on multiple interfaces, such as redundant networks.
 func WithTransmitInterface(interfaceName string) TransmitterOption {
 	return func(opts *transmitterOptions) {
-		opts.interfaceName = interfaceName
+		if interfaceName != "" {
+			opts.interfaceNames = append(opts.interfaceNames, interfaceName)
+		}
 	}
 }
 
@@ -69,9 +88,79 @@ func WithTransmitCompression(compressor Compressor, channels ...string) Transmit
 	}
 }
 
+// WithTransmitProtoTypeParam configures the transmitter to carry the full name of the proto message type in a "t"
+// channel param, such as "camera.front?t=example.Image", when a proto message is transmitted on a channel other than
+// its full name.
+//
+// This lets receivers decode a proto type transmitted on multiple channels. Receivers filter messages by channel and
+// not by type, so the channels must be subscribed to explicitly by receivers filtering channels. The params count
+// towards the 63 byte channel length limit, and receivers older than the type param may fail to decode compressed
+// messages with it.
+func WithTransmitProtoTypeParam(b bool) TransmitterOption {
+	return func(opts *transmitterOptions) {
+		opts.protoTypeParam = b
+	}
+}
+
+// WithTransmitSchemas configures the transmitter to publish the schemas of the proto messages it transmits on
+// SchemaChannel, when a proto message type is first transmitted and then periodically at the provided interval.
+//
+// The interval is counted from when the transmitter is dialed, not from when a type is first transmitted.
+//
+// A zero interval only publishes schemas when first transmitted, and when requested through ServeSchemaRequests.
+func WithTransmitSchemas(interval time.Duration) TransmitterOption {
+	return func(opts *transmitterOptions) {
+		opts.schemas = true
+		opts.schemaInterval = interval
+	}
+}
+
+// WithTransmitAnnouncements configures the transmitter to announce itself and the channels it publishes on
+// DiscoveryChannel, with the provided node name, immediately and then periodically at the provided interval.
+func WithTransmitAnnouncements(node string, interval time.Duration) TransmitterOption {
+	return func(opts *transmitterOptions) {
+		opts.nodeName = node
+		opts.announceInterval = interval
+	}
+}
+
 // WithTransmitTTL configures the multicast TTL on the transmitter socket.
 func WithTransmitTTL(ttl int) TransmitterOption {
 	return func(opts *transmitterOptions) {
 		opts.ttl = ttl
 	}
 }
+
+// WithTransmitSharedMemorySize configures the size (in bytes) of the ring buffer of a shared-memory transmitter, when
+// the ring buffer is created.
+//
+// The largest message that can be transmitted is half the size of the ring buffer.
+func WithTransmitSharedMemorySize(n int) TransmitterOption {
+	return func(opts *transmitterOptions) {
+		opts.sharedMemorySize = n
+	}
+}
+
+// WithTransmitMetrics configures the metrics to report transmitter instrumentation to.
+//
+// A nil metrics disables instrumentation.
+func WithTransmitMetrics(metrics TransmitterMetrics) TransmitterOption {
+	return func(opts *transmitterOptions) {
+		if metrics == nil {
+			metrics = noopMetrics{}
+		}
+		opts.metrics = metrics
+	}
+}
+
+// WithTransmitLogger configures the logger to emit structured events for transmitter setup and errors to.
+//
+// A nil logger discards all events.
+func WithTransmitLogger(logger *slog.Logger) TransmitterOption {
+	return func(opts *transmitterOptions) {
+		if logger == nil {
+			logger = slog.New(slog.DiscardHandler)
+		}
+		opts.logger = logger
+	}
+}
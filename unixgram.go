@@ -0,0 +1,213 @@
+package lcm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.einride.tech/lcm/compression/lcmlz4"
+	"golang.org/x/net/ipv4"
+	"google.golang.org/protobuf/proto"
+)
+
+// unixgramRefreshInterval is the interval at which transmitters rescan the socket directory for new receivers.
+const unixgramRefreshInterval = time.Second
+
+// unixgramSocketCount is the number of Unix datagram sockets created by this process, used for unique socket names.
+var unixgramSocketCount atomic.Uint64
+
+// ListenUnixgram returns a Receiver of the messages transmitted to the provided socket directory, configured with the
+// provided options.
+//
+// The receiver binds a uniquely named Unix datagram socket in the directory, which is removed when the receiver is
+// closed. The multicast options, such as the address, port and interfaces, are ignored. BPF filtering is not applied,
+// and messages on channels not subscribed to are discarded after being read.
+func ListenUnixgram(ctx context.Context, directory string, receiverOpts ...ReceiverOption) (*Receiver, error) {
+	opts := defaultReceiverOptions()
+	for _, receiverOpt := range receiverOpts {
+		receiverOpt(opts)
+	}
+	name := unixgramSocketName() + ".sock"
+	path := filepath.Join(directory, name)
+	if opts.socketMode != 0 {
+		// bind in a private directory and move the socket into place once its mode is set, so that it is never
+		// reachable with the default mode
+		privateDirectory, err := os.MkdirTemp(directory, ".lcm-")
+		if err != nil {
+			return nil, fmt.Errorf("listen unixgram: %w", err)
+		}
+		defer func() {
+			_ = os.RemoveAll(privateDirectory)
+		}()
+		path = filepath.Join(privateDirectory, name)
+	}
+	unixConn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("listen unixgram: %w", err)
+	}
+	if opts.socketMode != 0 {
+		if err := os.Chmod(path, opts.socketMode); err != nil {
+			return nil, fmt.Errorf("listen unixgram: %w", errors.Join(err, unixConn.Close()))
+		}
+		if err := os.Rename(path, filepath.Join(directory, name)); err != nil {
+			return nil, fmt.Errorf("listen unixgram: %w", errors.Join(err, unixConn.Close()))
+		}
+		path = filepath.Join(directory, name)
+	}
+	rx := &Receiver{
+		opts:          opts,
+		unixConn:      unixConn,
+		unixPath:      path,
+		subscription:  newChannelSubscription(opts.channels, opts.protos),
+		interfaces:    make(map[int]*net.Interface),
+		protoMessages: make(map[string]proto.Message),
+		decompressors: map[string]Decompressor{"z=lz4": lcmlz4.NewDecompressor()},
+		messageBuf:    []ipv4.Message{{Buffers: [][]byte{make([]byte, lengthOfLargestUDPMessage)}}},
+	}
+	if err := unixConn.SetReadBuffer(opts.bufferSizeBytes); err != nil {
+		return nil, fmt.Errorf("listen unixgram: setting read buffer: %w", errors.Join(err, rx.Close()))
+	}
+	for _, msg := range opts.registeredProtos() {
+		rx.protoMessages[string(msg.ProtoReflect().Descriptor().FullName())] = proto.Clone(msg)
+	}
+	opts.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"listening for LCM messages",
+		slog.String("address", path),
+		slog.Int("bufferSize", opts.bufferSizeBytes),
+	)
+	return rx, nil
+}
+
+// unixgramSocketName returns a unique name for a Unix datagram socket of this process.
+func unixgramSocketName() string {
+	return strconv.Itoa(os.Getpid()) + "." + strconv.FormatUint(unixgramSocketCount.Add(1), 10)
+}
+
+// readUnixgram reads a single datagram into the message buffer.
+func (r *Receiver) readUnixgram() (int, error) {
+	n, addr, err := r.unixConn.ReadFromUnix(r.messageBuf[0].Buffers[0])
+	if err != nil {
+		return 0, err
+	}
+	r.messageBuf[0].N = n
+	r.messageBuf[0].NN = 0
+	r.messageBuf[0].Addr = addr
+	return 1, nil
+}
+
+// closeUnixgram closes the receiver socket and removes it from the socket directory.
+func (r *Receiver) closeUnixgram() error {
+	if err := errors.Join(r.unixConn.Close(), os.Remove(r.unixPath)); err != nil {
+		return fmt.Errorf("close LCM receiver: %w", err)
+	}
+	return nil
+}
+
+// DialUnixgram returns a Transmitter to the receivers in the provided socket directory, configured with the provided
+// options.
+//
+// Each message is transmitted to every Unix datagram socket in the directory, which is rescanned for new receivers
+// every second. Like with multicast, messages to receivers with full receive buffers are dropped rather than blocking
+// the transmitter, except on non-Linux systems where the transmitter blocks. Transmitting requires write permission
+// on the receiver sockets, which can be used for access control, and receivers without permission are skipped. The
+// multicast options, such as the interfaces, addresses and the TTL, are ignored.
+func DialUnixgram(ctx context.Context, directory string, transmitterOpts ...TransmitterOption) (*Transmitter, error) {
+	opts := defaultTransmitterOptions()
+	for _, transmitterOpt := range transmitterOpts {
+		transmitterOpt(opts)
+	}
+	// the socket is outside the directory, so that it is not found by other transmitters scanning the directory
+	unixConn, err := net.ListenUnixgram("unixgram", unixgramTransmitterAddr())
+	if err != nil {
+		return nil, fmt.Errorf("dial unixgram: %w", err)
+	}
+	tx := &Transmitter{opts: opts, unixConn: unixConn, peers: &unixgramPeers{directory: directory}}
+	if err := tx.peers.refresh(time.Now()); err != nil {
+		return nil, fmt.Errorf("dial unixgram: %w", errors.Join(err, unixConn.Close()))
+	}
+	opts.logger.LogAttrs(
+		ctx,
+		slog.LevelDebug,
+		"transmitting LCM messages",
+		slog.String("directory", directory),
+		slog.Int("receivers", len(tx.peers.addrs)),
+	)
+	tx.startBackground()
+	return tx, nil
+}
+
+// transmitUnixgram transmits a datagram to every receiver socket in the socket directory.
+func (t *Transmitter) transmitUnixgram(ctx context.Context, b []byte) error {
+	if err := t.peers.refresh(time.Now()); err != nil {
+		return fmt.Errorf("transmit to LCM: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	if err := t.unixConn.SetWriteDeadline(deadline); err != nil {
+		return fmt.Errorf("transmit to LCM: %w", err)
+	}
+	for i := 0; i < len(t.peers.addrs); {
+		addr := t.peers.addrs[i]
+		switch err := sendUnixgram(t.unixConn, b, addr); {
+		case err == nil:
+		case errors.Is(err, syscall.EAGAIN):
+			// the receive buffer of the receiver is full
+			t.opts.logger.LogAttrs(
+				ctx, slog.LevelDebug, "dropped message to full receiver", slog.String("address", addr.Name),
+			)
+		case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ENOENT):
+			// the receiver is gone, and its socket is forgotten until it shows up in a rescan
+			t.opts.logger.LogAttrs(ctx, slog.LevelDebug, "forgot receiver", slog.String("address", addr.Name))
+			t.peers.addrs = slices.Delete(t.peers.addrs, i, i+1)
+			continue
+		case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+			// the receiver socket is not writable by the transmitter, and is forgotten until the next rescan
+			t.opts.logger.LogAttrs(
+				ctx, slog.LevelDebug, "forgot receiver without write permission", slog.String("address", addr.Name),
+			)
+			t.peers.addrs = slices.Delete(t.peers.addrs, i, i+1)
+			continue
+		default:
+			return fmt.Errorf("transmit to LCM: %w", err)
+		}
+		i++
+	}
+	return nil
+}
+
+// unixgramPeers are the receiver sockets in a socket directory.
+type unixgramPeers struct {
+	directory string
+	addrs     []*net.UnixAddr
+	scanned   time.Time
+}
+
+// refresh rescans the socket directory, unless it has been scanned within the refresh interval.
+func (p *unixgramPeers) refresh(now time.Time) error {
+	if !p.scanned.IsZero() && now.Sub(p.scanned) < unixgramRefreshInterval {
+		return nil
+	}
+	entries, err := os.ReadDir(p.directory)
+	if err != nil {
+		return fmt.Errorf("scanning socket directory: %w", err)
+	}
+	p.addrs = p.addrs[:0]
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSocket != 0 {
+			p.addrs = append(p.addrs, &net.UnixAddr{Name: filepath.Join(p.directory, entry.Name()), Net: "unixgram"})
+		}
+	}
+	p.scanned = now
+	return nil
+}
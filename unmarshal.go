@@ -0,0 +1,153 @@
+package lcm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// unmarshalOptions are the configuration options for decoding a received proto message.
+type unmarshalOptions struct {
+	merge bool
+	pool  *ProtoPool
+}
+
+// UnmarshalOption configures decoding of a received proto message.
+//
+// WithUnmarshalMerge only applies to Receiver.UnmarshalProto, and WithUnmarshalPool only applies to Receiver.NewProto.
+type UnmarshalOption func(*unmarshalOptions)
+
+// WithUnmarshalMerge configures decoding to merge the received message into the provided message, instead of
+// resetting the provided message first.
+//
+// Only applies to Receiver.UnmarshalProto. Receiver.NewProto always decodes into a new, empty message.
+func WithUnmarshalMerge(b bool) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.merge = b
+	}
+}
+
+// WithUnmarshalPool configures the pool to draw new proto messages from.
+//
+// Only applies to Receiver.NewProto. Receiver.UnmarshalProto decodes into the provided message.
+func WithUnmarshalPool(pool *ProtoPool) UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.pool = pool
+	}
+}
+
+// ProtoPool is a pool of proto messages, with a pool per message type.
+//
+// The zero value is ready to use. Safe for concurrent use.
+type ProtoPool struct {
+	mu    sync.Mutex
+	pools map[protoreflect.FullName]*sync.Pool
+}
+
+// Get a proto message of the provided type from the pool.
+func (p *ProtoPool) Get(mt protoreflect.MessageType) proto.Message {
+	return p.pool(mt).Get().(proto.Message)
+}
+
+// Put resets a proto message and returns it to the pool. The message must not be used after being returned.
+func (p *ProtoPool) Put(m proto.Message) {
+	proto.Reset(m)
+	p.pool(m.ProtoReflect().Type()).Put(m)
+}
+
+// pool returns the pool for the message type.
+func (p *ProtoPool) pool(mt protoreflect.MessageType) *sync.Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := mt.Descriptor().FullName()
+	pool, ok := p.pools[name]
+	if !ok {
+		if p.pools == nil {
+			p.pools = make(map[protoreflect.FullName]*sync.Pool)
+		}
+		pool = &sync.Pool{
+			New: func() any {
+				return mt.New().Interface()
+			},
+		}
+		p.pools[name] = pool
+	}
+	return pool
+}
+
+// UnmarshalProto decodes the last received message into the provided proto message.
+//
+// Unlike ReceiveProto, the proto message is owned by the caller, and is not overwritten by later received messages.
+// The provided message is reset before decoding, unless configured to merge with WithUnmarshalMerge. The pool of
+// WithUnmarshalPool is ignored.
+func (r *Receiver) UnmarshalProto(ctx context.Context, m proto.Message, unmarshalOpts ...UnmarshalOption) error {
+	var opts unmarshalOptions
+	for _, unmarshalOpt := range unmarshalOpts {
+		unmarshalOpt(&opts)
+	}
+	return r.decodeProto(ctx, m, opts.merge)
+}
+
+// NewProto decodes the last received message into a new instance of the registered proto message of its type.
+//
+// The new message is drawn from a pool when configured with WithUnmarshalPool, and can be returned to the pool when
+// the caller is done with it. Merging with WithUnmarshalMerge is ignored, since the new message is empty.
+//
+// Messages of types without a registered proto message result in an error wrapping ErrUnregisteredProto.
+func (r *Receiver) NewProto(ctx context.Context, unmarshalOpts ...UnmarshalOption) (proto.Message, error) {
+	var opts unmarshalOptions
+	for _, unmarshalOpt := range unmarshalOpts {
+		unmarshalOpt(&opts)
+	}
+	name := r.protoType()
+	r.mu.Lock()
+	registered, ok := r.protoMessages[name]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("new proto %s on LCM: %w", name, ErrUnregisteredProto)
+	}
+	mt := registered.ProtoReflect().Type()
+	var m proto.Message
+	if opts.pool != nil {
+		m = opts.pool.Get(mt)
+	} else {
+		m = mt.New().Interface()
+	}
+	if err := r.decodeProto(ctx, m, true); err != nil {
+		if opts.pool != nil {
+			opts.pool.Put(m)
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeProto decodes the data of the last received message into the proto message.
+func (r *Receiver) decodeProto(ctx context.Context, m proto.Message, merge bool) error {
+	return r.decodeProtoMessage(ctx, &r.currMessage, m, merge)
+}
+
+// decodeProtoMessage decodes the data of the message into the proto message.
+//
+// Safe for concurrent use, to decode messages owned by other goroutines.
+func (r *Receiver) decodeProtoMessage(ctx context.Context, msg *Message, m proto.Message, merge bool) error {
+	if err := (proto.UnmarshalOptions{Merge: merge}).Unmarshal(msg.Data, m); err != nil {
+		r.opts.metrics.DecodeFailed(msg.Channel)
+		r.opts.logger.LogAttrs(
+			ctx,
+			slog.LevelDebug,
+			"failed to decode proto message",
+			slog.String("channel", msg.Channel),
+			slog.Any("error", err),
+		)
+		return fmt.Errorf("receive proto %s on LCM: %w", msg.Channel, &MalformedMessageError{
+			Channel: msg.Channel,
+			Err:     err,
+		})
+	}
+	return nil
+}